@@ -2,16 +2,20 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
-	"github.com/joho/godotenv"
+	"github.com/kollinn/casamento-mari-kollinn/internal/config"
 	"github.com/kollinn/casamento-mari-kollinn/internal/handler"
+	"github.com/kollinn/casamento-mari-kollinn/internal/rsvp"
 	"github.com/kollinn/casamento-mari-kollinn/internal/service"
 )
 
@@ -20,25 +24,73 @@ func main() {
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
 	log.Println("Iniciando servidor...")
 
-	// Carrega as variáveis de ambiente do arquivo .env
-	if err := godotenv.Load(); err != nil {
-		log.Printf("Aviso: Arquivo .env não encontrado: %v", err)
+	// Carrega as configurações (.env, arquivo YAML e variáveis de ambiente)
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Erro ao carregar as configurações: %v", err)
 	}
 
 	router := mux.NewRouter()
 
-	// Inicializa o serviço do Drive
-	driveService := service.NewDriveService()
-	if driveService == nil {
-		log.Println("AVISO: GOOGLE_DRIVE_FOLDER_ID não configurado. Use variável de ambiente para definir a pasta do Drive.")
-	}
+	// Inicializa a fonte de imagens da galeria
+	imageSource := newImageSource(cfg)
 
 	// Inicializa o serviço de textos alternativos
-	altTextService := service.NewAltTextService()
+	altTextService := service.NewAltTextService(cfg)
 	log.Println("Serviço de textos alternativos inicializado")
 
+	// Inicializa o cache em disco das imagens servidas pelo proxy
+	diskCache := newDiskImageCache(cfg.ImageCache)
+
 	// Inicializa handlers
-	imageHandler := handler.NewImageHandler(driveService, altTextService)
+	imageHandler := handler.NewImageHandler(imageSource, altTextService, diskCache)
+	adminHandler := handler.NewAdminHandler(cfg.AdminToken, imageSource, imageHandler)
+
+	// Contexto cancelado ao receber SIGTERM (enviado pelo Render a cada deploy) ou SIGINT
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Tarefas em segundo plano, aguardadas no encerramento
+	var wg sync.WaitGroup
+
+	// Mantém a lista de imagens do Drive atualizada em segundo plano
+	var refresher service.BackgroundRefresher
+	if driveService, ok := imageSource.(*service.DriveService); ok {
+		refresher = driveService
+		driveService.OnRefresh(imageHandler.InvalidateCache)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			driveService.RunRefresher(ctx)
+		}()
+
+		// Notificações push do Drive quando o endereço público está configurado
+		if cfg.Drive.WebhookURL != "" {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				driveService.RunWatcher(ctx, cfg.Drive.WebhookURL, cfg.Drive.WebhookToken)
+			}()
+		}
+	}
+	webhookHandler := handler.NewWebhookHandler(cfg.Drive.WebhookToken, refresher, imageHandler)
+	healthHandler := handler.NewHealthHandler(imageSource, altTextService)
+
+	// Confirmações de presença
+	rsvpStore, err := rsvp.NewStore(cfg.DataPath("rsvp.json"))
+	if err != nil {
+		log.Fatalf("Erro ao carregar as confirmações de presença: %v", err)
+	}
+	guestStore, err := rsvp.NewGuestStore(cfg.DataPath("guests.json"))
+	if err != nil {
+		log.Fatalf("Erro ao carregar a lista de convidados: %v", err)
+	}
+	rsvpForm, err := rsvp.LoadForm(cfg.RSVP.FormFile)
+	if err != nil {
+		log.Fatalf("Erro ao carregar o questionário de confirmação de presença: %v", err)
+	}
+	rsvpDeadline, _ := cfg.RSVP.DeadlineTime() // já validado em config.Load
+	rsvpHandler := rsvp.NewHandler(rsvpStore, guestStore, rsvpForm, rsvpDeadline, cfg.Server.TrustProxy)
 
 	// Registra rotas para API de imagens
 	apiRouter := router.PathPrefix("/api").Subrouter()
@@ -47,6 +99,29 @@ func main() {
 	apiRouter.HandleFunc("/images/{id}/proxy", imageHandler.ProxyImage).Methods("GET")
 	apiRouter.HandleFunc("/images/{id}/alt-text", imageHandler.GetAltText).Methods("GET")
 	apiRouter.HandleFunc("/images/{id}/alt-text", imageHandler.SetAltText).Methods("POST")
+	apiRouter.HandleFunc("/albums", imageHandler.GetAlbums).Methods("GET")
+	apiRouter.HandleFunc("/albums/{id}/images", imageHandler.GetAlbumImages).Methods("GET")
+
+	// Confirmação de presença (página /confirmar)
+	apiRouter.HandleFunc("/rsvp", rsvpHandler.Create).Methods("POST")
+	apiRouter.HandleFunc("/rsvp/form", rsvpHandler.Form).Methods("GET")
+	apiRouter.HandleFunc("/rsvp/{id}", rsvpHandler.Get).Methods("GET")
+	apiRouter.HandleFunc("/rsvp/invitation/{code}", rsvpHandler.Invitation).Methods("GET")
+	apiRouter.HandleFunc("/rsvp/invitation/{code}", rsvpHandler.Update).Methods("PUT")
+	apiRouter.HandleFunc("/rsvp/invitation/{code}", rsvpHandler.Withdraw).Methods("DELETE")
+
+	// Notificações de mudanças do Google Drive (changes.watch)
+	apiRouter.HandleFunc("/hooks/drive", webhookHandler.DriveNotification).Methods("POST")
+
+	// Rotas administrativas protegidas por ADMIN_TOKEN
+	adminRouter := apiRouter.PathPrefix("/admin").Subrouter()
+	adminRouter.Use(adminHandler.RequireToken)
+	adminRouter.HandleFunc("/refresh", adminHandler.RefreshImages).Methods("POST")
+	adminRouter.HandleFunc("/rsvp/changes", rsvpHandler.Changes).Methods("GET")
+
+	// Verificações de saúde da plataforma
+	router.HandleFunc("/healthz", healthHandler.Healthz).Methods("GET", "HEAD")
+	router.HandleFunc("/readyz", healthHandler.Readyz).Methods("GET", "HEAD")
 
 	// Configuração para servir arquivos HTML específicos em rotas específicas
 	router.HandleFunc("/", serveIndexPage)
@@ -59,23 +134,16 @@ func main() {
 	fs := http.FileServer(http.Dir("static"))
 	router.PathPrefix("/").Handler(http.StripPrefix("/", fs))
 
-	// Determina a porta para o servidor
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "3000"
-	}
-
-	// Pré-carrega as imagens do Drive em uma goroutine separada para não bloquear o servidor
-	var wg sync.WaitGroup
-	if driveService != nil {
+	// Pré-carrega as imagens em uma goroutine separada para não bloquear o servidor
+	if imageSource != nil {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+			ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
 			defer cancel()
 
 			log.Println("Iniciando pré-carregamento das imagens em segundo plano...")
-			_, err := driveService.GetDriveImages(ctx)
+			_, err := imageSource.ListImages(ctx)
 			if err != nil {
 				log.Printf("Aviso: Erro ao pré-carregar imagens: %v", err)
 			}
@@ -84,15 +152,91 @@ func main() {
 	}
 
 	// Inicia o servidor
-	log.Printf("Servidor iniciando na porta %s", port)
-	log.Printf("Acesse http://localhost:%s/galeria para ver a galeria", port)
-
-	// Se quiser aguardar o carregamento completo:
-	// wg.Wait()
-	// log.Println("Todas as inicializações concluídas, servidor pronto")
+	log.Printf("Servidor iniciando na porta %s", cfg.Port)
+	log.Printf("Acesse http://localhost:%s/galeria para ver a galeria", cfg.Port)
 
 	// Inicia o servidor HTTP
-	log.Fatal(http.ListenAndServe(":"+port, router))
+	server := &http.Server{
+		Addr:              ":" + cfg.Port,
+		Handler:           router,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+	}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Erro no servidor HTTP: %v", err)
+		}
+	}()
+
+	// Aguarda o sinal de encerramento
+	<-ctx.Done()
+	stop()
+	log.Printf("Encerrando o servidor (aguardando até %s pelas requisições em andamento)...", cfg.Server.ShutdownTimeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	// Para de aceitar conexões e espera as requisições (e downloads do proxy) terminarem
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Aviso: requisições interrompidas no encerramento: %v", err)
+	}
+
+	// O contexto já foi cancelado; espera o atualizador e o canal do Drive pararem
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-shutdownCtx.Done():
+		log.Println("Aviso: tarefas em segundo plano não terminaram a tempo")
+	}
+
+	// Grava os textos alternativos pendentes
+	if err := altTextService.Close(); err != nil {
+		log.Printf("Erro ao gravar textos alternativos no encerramento: %v", err)
+	}
+
+	log.Println("Servidor encerrado")
+}
+
+// newImageSource cria a fonte de imagens da galeria. Retorna nil quando
+// nenhuma fonte está configurada, permitindo rodar o site sem o Google Drive.
+func newImageSource(cfg *config.Config) service.ImageSource {
+	// Um diretório local tem prioridade sobre o Drive (modo offline e CI)
+	if cfg.Gallery.Dir != "" {
+		return service.NewLocalImageSource(cfg.Gallery.Dir)
+	}
+
+	// Bucket compatível com S3 entregue pelo fotógrafo
+	if cfg.S3.Bucket != "" {
+		return service.NewS3ImageSource(cfg.S3)
+	}
+
+	driveService := service.NewDriveService(cfg)
+	if driveService == nil {
+		log.Println("AVISO: GOOGLE_DRIVE_FOLDER_ID não configurado. Use variável de ambiente para definir a pasta do Drive.")
+		return nil
+	}
+	return driveService
+}
+
+// newDiskImageCache cria o cache em disco do proxy de imagens.
+// IMAGE_CACHE_MAX_MB=0 desabilita o cache.
+func newDiskImageCache(cacheConfig config.ImageCacheConfig) *service.DiskImageCache {
+	if cacheConfig.MaxMB == 0 {
+		log.Println("Cache de imagens em disco desabilitado")
+		return nil
+	}
+
+	diskCache, err := service.NewDiskImageCache(cacheConfig.Dir, cacheConfig.MaxMB<<20)
+	if err != nil {
+		log.Printf("AVISO: Cache de imagens em disco indisponível: %v", err)
+		return nil
+	}
+	return diskCache
 }
 
 // Função para servir a página inicial
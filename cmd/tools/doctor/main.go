@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/kollinn/casamento-mari-kollinn/internal/config"
+	"github.com/kollinn/casamento-mari-kollinn/internal/model"
+	"github.com/kollinn/casamento-mari-kollinn/internal/rsvp"
+	"github.com/kollinn/casamento-mari-kollinn/internal/service"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/drive/v3"
+)
+
+// Arquivos servidos pelo servidor que precisam existir em static/
+var requiredStaticFiles = []string{
+	"index.html",
+	"confirmar.html",
+	"galeria.html",
+	"informacoes.html",
+	"localizacao.html",
+	"style.css",
+	"js/script.js",
+}
+
+// report acumula o resultado das verificações
+type report struct {
+	failures int
+	warnings int
+}
+
+func (r *report) pass(name, detail string) {
+	fmt.Printf("[ OK  ] %s: %s\n", name, detail)
+}
+
+func (r *report) warn(name, detail string) {
+	r.warnings++
+	fmt.Printf("[AVISO] %s: %s\n", name, detail)
+}
+
+func (r *report) fail(name string, err error) {
+	r.failures++
+	fmt.Printf("[FALHA] %s: %v\n", name, err)
+}
+
+// Verifica a configuração de implantação e imprime um relatório.
+// Sai com status 1 se alguma verificação falhar.
+func main() {
+	staticDir := flag.String("static", "static", "diretório dos arquivos estáticos")
+	timeout := flag.Duration("timeout", 2*time.Minute, "tempo máximo para as verificações remotas")
+	verbose := flag.Bool("v", false, "exibe os logs dos serviços")
+	flag.Parse()
+
+	if !*verbose {
+		log.SetOutput(io.Discard)
+	}
+
+	r := &report{}
+
+	cfg, err := config.Load()
+	if err != nil {
+		r.fail("Configuração", err)
+		finish(r)
+	}
+	if cfg.File != "" {
+		r.pass("Configuração", "carregada de "+cfg.File)
+	} else {
+		r.pass("Configuração", "carregada do ambiente")
+	}
+
+	checkStaticFiles(r, *staticDir)
+	checkDataDir(r, cfg.DataDir)
+	checkRSVPForm(r, cfg.RSVP.FormFile)
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	switch {
+	case cfg.Gallery.Dir != "":
+		checkImageSource(ctx, r, "Galeria local", service.NewLocalImageSource(cfg.Gallery.Dir))
+	case cfg.S3.Bucket != "":
+		checkImageSource(ctx, r, "Bucket S3", service.NewS3ImageSource(cfg.S3))
+	case cfg.Drive.FolderID != "":
+		checkDrive(ctx, r, cfg)
+	default:
+		r.warn("Galeria", "nenhuma fonte de imagens configurada (GALLERY_DIR, S3_BUCKET ou GOOGLE_DRIVE_FOLDER_ID); a galeria ficará indisponível")
+	}
+
+	finish(r)
+}
+
+// finish imprime o resumo e encerra com o status adequado
+func finish(r *report) {
+	fmt.Println()
+	if r.failures > 0 {
+		fmt.Printf("%d verificação(ões) falharam, %d aviso(s)\n", r.failures, r.warnings)
+		os.Exit(1)
+	}
+	fmt.Printf("Tudo certo (%d aviso(s))\n", r.warnings)
+}
+
+// checkStaticFiles confere se as páginas e os recursos estáticos existem
+func checkStaticFiles(r *report, dir string) {
+	var missing []string
+	for _, name := range requiredStaticFiles {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			missing = append(missing, name)
+		}
+	}
+
+	if len(missing) > 0 {
+		r.fail("Arquivos estáticos", fmt.Errorf("ausentes em %s: %v", dir, missing))
+		return
+	}
+	r.pass("Arquivos estáticos", fmt.Sprintf("%d arquivos encontrados em %s", len(requiredStaticFiles), dir))
+}
+
+// checkDataDir confere se o diretório de dados existe e aceita gravações
+func checkDataDir(r *report, dir string) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		r.fail("Diretório de dados", err)
+		return
+	}
+
+	file, err := os.CreateTemp(dir, ".doctor-*")
+	if err != nil {
+		r.fail("Diretório de dados", fmt.Errorf("%s não aceita gravações: %v", dir, err))
+		return
+	}
+	file.Close()
+	os.Remove(file.Name())
+
+	r.pass("Diretório de dados", dir+" gravável")
+
+	// Fora de um volume, as respostas do RSVP e os convidados somem a cada deploy
+	mount, err := findMountPoint(dir)
+	switch {
+	case err != nil:
+		r.warn("Persistência dos dados", fmt.Sprintf("não foi possível verificar se %s está em um volume persistente: %v", dir, err))
+	case !mount.persistent():
+		r.warn("Persistência dos dados", fmt.Sprintf("%s não está em um volume persistente (%s em %s) e pode ser apagado a cada implantação; aponte DATA_DIR para um disco persistente", dir, mount.fsType, mount.path))
+	default:
+		r.pass("Persistência dos dados", fmt.Sprintf("%s no volume montado em %s (%s)", dir, mount.path, mount.fsType))
+	}
+}
+
+// checkRSVPForm confere se o questionário de confirmação de presença é válido
+func checkRSVPForm(r *report, file string) {
+	form, err := rsvp.LoadForm(file)
+	if err != nil {
+		r.fail("Questionário RSVP", err)
+		return
+	}
+	source := "padrão"
+	if file != "" {
+		source = file
+	}
+	r.pass("Questionário RSVP", fmt.Sprintf("%d pergunta(s) (%s)", len(form.Questions), source))
+}
+
+// checkImageSource lista as imagens de uma fonte local ou S3
+func checkImageSource(ctx context.Context, r *report, name string, source service.ImageSource) {
+	images, err := source.ListImages(ctx)
+	if err != nil {
+		r.fail(name, err)
+		return
+	}
+	reportImages(r, images)
+}
+
+// checkDrive verifica credenciais, token, escopos, pasta e imagens do Drive
+func checkDrive(ctx context.Context, r *report, cfg *config.Config) {
+	if !checkDriveCredentials(r, cfg) {
+		return
+	}
+
+	driveService := service.NewDriveService(cfg)
+
+	info, err := driveService.TokenInfo(ctx)
+	if err != nil {
+		r.fail("Token", err)
+		return
+	}
+	r.pass("Token", fmt.Sprintf("válido até %s", info.Expiry.Format("02/01/2006 15:04")))
+	if !info.ServiceAccount && !info.HasRefreshToken {
+		r.fail("Refresh token", fmt.Errorf("token.json sem refresh token; o acesso expira em uma hora, execute setup_auth"))
+	}
+
+	if info.HasScope(drive.DriveReadonlyScope) || info.HasScope(drive.DriveScope) {
+		r.pass("Escopos", fmt.Sprintf("%v", info.Scopes))
+	} else {
+		r.fail("Escopos", fmt.Errorf("o token não concede %s (concedidos: %v)", drive.DriveReadonlyScope, info.Scopes))
+		return
+	}
+
+	folderName, err := driveService.CheckFolder(ctx)
+	if err != nil {
+		r.fail("Pasta do Drive", err)
+		return
+	}
+	r.pass("Pasta do Drive", fmt.Sprintf("%q (%s)", folderName, cfg.Drive.FolderID))
+
+	images, err := driveService.FetchImages(ctx)
+	if err != nil {
+		r.fail("Imagens", err)
+		return
+	}
+	reportImages(r, images)
+}
+
+// checkDriveCredentials confere os arquivos de credenciais sem acessar a rede
+func checkDriveCredentials(r *report, cfg *config.Config) bool {
+	if cfg.Drive.ServiceAccountFile != "" {
+		keyBytes, err := os.ReadFile(cfg.Drive.ServiceAccountFile)
+		if err != nil {
+			r.fail("Conta de serviço", err)
+			return false
+		}
+		jwtConfig, err := google.JWTConfigFromJSON(keyBytes, drive.DriveReadonlyScope)
+		if err != nil {
+			r.fail("Conta de serviço", fmt.Errorf("chave inválida: %v", err))
+			return false
+		}
+		detail := jwtConfig.Email
+		if cfg.Drive.Subject != "" {
+			detail += " em nome de " + cfg.Drive.Subject
+		}
+		r.pass("Conta de serviço", detail)
+		return true
+	}
+
+	credPath := cfg.ConfigPath("credentials.json")
+	credBytes, err := os.ReadFile(credPath)
+	if err != nil {
+		r.fail("Credenciais", err)
+		return false
+	}
+	if _, err := google.ConfigFromJSON(credBytes, drive.DriveReadonlyScope); err != nil {
+		r.fail("Credenciais", fmt.Errorf("%s inválido: %v", credPath, err))
+		return false
+	}
+	r.pass("Credenciais", credPath)
+
+	tokenPath := cfg.ConfigPath("token.json")
+	tokenBytes, err := os.ReadFile(tokenPath)
+	if err != nil {
+		r.fail("Arquivo de token", fmt.Errorf("%v; execute setup_auth", err))
+		return false
+	}
+	token := &oauth2.Token{}
+	if err := json.Unmarshal(tokenBytes, token); err != nil {
+		r.fail("Arquivo de token", fmt.Errorf("%s inválido: %v", tokenPath, err))
+		return false
+	}
+
+	if stat, err := os.Stat(tokenPath); err == nil && stat.Mode().Perm()&0077 != 0 {
+		r.warn("Arquivo de token", fmt.Sprintf("%s com permissões %v; use 0600", tokenPath, stat.Mode().Perm()))
+	} else {
+		r.pass("Arquivo de token", tokenPath)
+	}
+	return true
+}
+
+// reportImages informa quantas imagens e álbuns estão visíveis
+func reportImages(r *report, images []model.DriveImage) {
+	if len(images) == 0 {
+		r.warn("Imagens", "nenhuma imagem encontrada")
+		return
+	}
+	albums := service.GroupAlbums(images)
+	r.pass("Imagens", fmt.Sprintf("%d imagens em %d álbum(ns)", len(images), len(albums)))
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// mountInfoPath lista os pontos de montagem do processo (Linux)
+const mountInfoPath = "/proc/self/mountinfo"
+
+// ephemeralFSTypes são sistemas de arquivos apagados quando o contêiner é recriado
+var ephemeralFSTypes = map[string]bool{
+	"tmpfs":   true,
+	"ramfs":   true,
+	"overlay": true,
+}
+
+// mountPoint identifica o ponto de montagem que contém um diretório
+type mountPoint struct {
+	path   string
+	fsType string
+}
+
+// persistent indica se o ponto de montagem sobrevive à recriação do contêiner:
+// a raiz e os sistemas de arquivos em memória não sobrevivem
+func (m mountPoint) persistent() bool {
+	return m.path != "/" && !ephemeralFSTypes[m.fsType]
+}
+
+// findMountPoint retorna o ponto de montagem mais específico que contém dir
+func findMountPoint(dir string) (mountPoint, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return mountPoint{}, err
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		abs = resolved
+	}
+
+	file, err := os.Open(mountInfoPath)
+	if err != nil {
+		return mountPoint{}, err
+	}
+	defer file.Close()
+
+	var best mountPoint
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		mount, ok := parseMountInfoLine(scanner.Text())
+		if !ok || !containsPath(mount.path, abs) {
+			continue
+		}
+		if len(mount.path) >= len(best.path) {
+			best = mount
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return mountPoint{}, err
+	}
+	if best.path == "" {
+		return mountPoint{}, fmt.Errorf("nenhum ponto de montagem contém %s", abs)
+	}
+	return best, nil
+}
+
+// parseMountInfoLine lê o ponto de montagem (5º campo) e o tipo do sistema de
+// arquivos (primeiro campo após o separador "-") de uma linha do mountinfo
+func parseMountInfoLine(line string) (mountPoint, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 5 {
+		return mountPoint{}, false
+	}
+	mount := mountPoint{path: unescapeMountPath(fields[4])}
+	for i := 5; i < len(fields)-1; i++ {
+		if fields[i] == "-" {
+			mount.fsType = fields[i+1]
+			break
+		}
+	}
+	return mount, true
+}
+
+// unescapeMountPath desfaz o escape octal (\040 para espaço) do mountinfo
+func unescapeMountPath(path string) string {
+	return strings.NewReplacer(`\040`, " ", `\011`, "\t", `\012`, "\n", `\134`, `\`).Replace(path)
+}
+
+// containsPath indica se path está dentro de root (ou é o próprio root)
+func containsPath(root, path string) bool {
+	if root == "/" || root == path {
+		return true
+	}
+	return strings.HasPrefix(path, root+"/")
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/kollinn/casamento-mari-kollinn/internal/config"
+	"github.com/kollinn/casamento-mari-kollinn/internal/rsvp"
+)
+
+// Colunas aceitas no CSV; apenas familia e convidados são obrigatórias
+const (
+	columnHousehold  = "familia"
+	columnGuests     = "convidados"
+	columnCompanions = "acompanhantes"
+	columnCode       = "codigo"
+	columnContact    = "contato"
+)
+
+// Importa a lista de convidados de um arquivo CSV e imprime, em CSV, o código
+// e o link de confirmação de cada convite
+func main() {
+	replace := flag.Bool("replace", false, "remove os convites que não estão no arquivo")
+	baseURL := flag.String("base-url", "", "endereço do site, para imprimir os links (ex: https://mariekollinn.com.br)")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Uso: import_guests [-replace] [-base-url URL] convidados.csv")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Colunas do CSV (com cabeçalho): familia, convidados, acompanhantes, codigo, contato")
+		fmt.Fprintln(os.Stderr, "Os nomes em 'convidados' são separados por ';'. Sem 'codigo', um código é gerado")
+		fmt.Fprintln(os.Stderr, "(ou mantido, se a família já foi importada antes).")
+		fmt.Fprintln(os.Stderr, "")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Erro ao carregar as configurações: %v", err)
+	}
+
+	file, err := os.Open(flag.Arg(0))
+	if err != nil {
+		log.Fatalf("Não foi possível abrir o CSV: %v", err)
+	}
+	defer file.Close()
+
+	households, err := readHouseholds(file)
+	if err != nil {
+		log.Fatalf("Erro no CSV: %v", err)
+	}
+
+	store, err := rsvp.NewGuestStore(cfg.DataPath("guests.json"))
+	if err != nil {
+		log.Fatalf("Erro ao carregar a lista de convidados: %v", err)
+	}
+
+	imported, err := store.Import(households, *replace)
+	if err != nil {
+		log.Fatalf("Erro ao importar convidados: %v", err)
+	}
+	fmt.Fprintf(os.Stderr, "%d convites importados em %s\n", len(imported), cfg.DataPath("guests.json"))
+
+	writer := csv.NewWriter(os.Stdout)
+	writer.Write([]string{columnHousehold, columnCode, "link"})
+	for _, household := range imported {
+		writer.Write([]string{household.Name, household.Code, invitationLink(*baseURL, household.Code)})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		log.Fatalf("Erro ao escrever a saída: %v", err)
+	}
+}
+
+// readHouseholds lê os convites do CSV, localizando as colunas pelo cabeçalho
+func readHouseholds(r io.Reader) ([]rsvp.Household, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("não foi possível ler o cabeçalho: %v", err)
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		// Planilhas exportadas pelo Excel começam com BOM
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	for _, required := range []string{columnHousehold, columnGuests} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("coluna obrigatória %q ausente", required)
+		}
+	}
+
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var households []rsvp.Household
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		household := rsvp.Household{
+			Name:    field(record, columnHousehold),
+			Guests:  strings.Split(field(record, columnGuests), ";"),
+			Code:    field(record, columnCode),
+			Contact: field(record, columnContact),
+		}
+		if value := field(record, columnCompanions); value != "" {
+			companions, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("linha %d: acompanhantes inválido (%q)", line, value)
+			}
+			household.MaxCompanions = companions
+		}
+		households = append(households, household)
+	}
+
+	return households, nil
+}
+
+// invitationLink monta o link de confirmação com o código do convite
+func invitationLink(baseURL, code string) string {
+	if baseURL == "" {
+		return ""
+	}
+	return strings.TrimSuffix(baseURL, "/") + "/confirmar?code=" + url.QueryEscape(code)
+}
@@ -2,56 +2,125 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"log"
+	"net"
+	"net/http"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"runtime"
+	"time"
 
+	"github.com/kollinn/casamento-mari-kollinn/internal/config"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 	"google.golang.org/api/drive/v3"
 )
 
+// Tempo máximo de espera pela autorização no navegador
+const authTimeout = 5 * time.Minute
+
+// authResult é o resultado recebido no redirecionamento do Google
+type authResult struct {
+	code string
+	err  error
+}
+
 // Estrutura para armazenar as credenciais do OAuth2
 func main() {
-	// Verifica argumentos
-	if len(os.Args) < 2 {
-		fmt.Println("Uso: setup_auth <caminho_credenciais.json>")
-		fmt.Println("Exemplo: setup_auth ./credentials.json")
-		os.Exit(1)
+	// Sem argumentos, usa o credentials.json do diretório de configuração
+	var credPath string
+	if len(os.Args) >= 2 {
+		credPath = os.Args[1]
+	} else {
+		cfg, err := config.Load()
+		if err != nil {
+			fmt.Println("Uso: setup_auth [caminho_credenciais.json]")
+			log.Fatalf("Erro ao carregar as configurações: %v", err)
+		}
+		credPath = cfg.ConfigPath("credentials.json")
+		fmt.Printf("Usando credenciais de %s\n", credPath)
 	}
-
-	credPath := os.Args[1]
 	// Lê as credenciais do OAuth2
-	credBytes, err := ioutil.ReadFile(credPath)
+	credBytes, err := os.ReadFile(credPath)
 	if err != nil {
 		log.Fatalf("Não foi possível ler o arquivo de credenciais: %v", err)
 	}
 
 	// Configura o escopo para acesso de leitura ao Drive
-	config, err := google.ConfigFromJSON(credBytes, drive.DriveReadonlyScope)
+	oauthConfig, err := google.ConfigFromJSON(credBytes, drive.DriveReadonlyScope)
 	if err != nil {
 		log.Fatalf("Não foi possível analisar as credenciais: %v", err)
 	}
 
-	// Gera a URL de autorização
-	authURL := config.AuthCodeURL("state-token", oauth2.AccessTypeOffline)
+	// Abre uma porta local para receber o redirecionamento do Google
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		log.Fatalf("Não foi possível abrir a porta local: %v", err)
+	}
+	oauthConfig.RedirectURL = fmt.Sprintf("http://%s/", listener.Addr().String())
+
+	// State aleatório contra CSRF e verificador PKCE
+	state, err := randomState()
+	if err != nil {
+		log.Fatalf("Não foi possível gerar o state: %v", err)
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	// Gera a URL de autorização. ApprovalForce garante um novo refresh token
+	// mesmo quando o aplicativo já foi autorizado antes.
+	authURL := oauthConfig.AuthCodeURL(state,
+		oauth2.AccessTypeOffline,
+		oauth2.ApprovalForce,
+		oauth2.S256ChallengeOption(verifier),
+	)
+
+	results := make(chan authResult, 1)
+	server := &http.Server{
+		Handler:           callbackHandler(state, results),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			results <- authResult{err: fmt.Errorf("erro no servidor local: %v", err)}
+		}
+	}()
+
 	fmt.Printf("Acesse este URL para autorizar o aplicativo:\n%v\n\n", authURL)
-	fmt.Println("Cole o código de autorização recebido:")
+	if err := openBrowser(authURL); err == nil {
+		fmt.Println("O navegador foi aberto. Faça login e autorize o acesso.")
+	}
+	fmt.Println("Aguardando a autorização...")
+
+	// Aguarda o redirecionamento com o código de autorização
+	var result authResult
+	select {
+	case result = <-results:
+	case <-time.After(authTimeout):
+		result = authResult{err: fmt.Errorf("tempo esgotado aguardando a autorização")}
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	server.Shutdown(shutdownCtx)
 
-	// Lê o código de autorização do usuário
-	var authCode string
-	if _, err := fmt.Scan(&authCode); err != nil {
-		log.Fatalf("Não foi possível ler o código de autorização: %v", err)
+	if result.err != nil {
+		log.Fatalf("Autorização não concluída: %v", result.err)
 	}
 
 	// Troca o código de autorização por um token
-	token, err := config.Exchange(context.Background(), authCode)
+	token, err := oauthConfig.Exchange(context.Background(), result.code, oauth2.VerifierOption(verifier))
 	if err != nil {
 		log.Fatalf("Não foi possível trocar o código pelo token: %v", err)
 	}
+	if token.RefreshToken == "" {
+		fmt.Println("[AVISO] O Google não devolveu um refresh token; o acesso expira em cerca de uma hora.")
+	}
 
 	// Salva o token em um arquivo
 	tokenPath := filepath.Join(filepath.Dir(credPath), "token.json")
@@ -62,6 +131,68 @@ func main() {
 	fmt.Println("GOOGLE_DRIVE_FOLDER_ID=<id_da_pasta_com_fotos>")
 }
 
+// callbackHandler recebe o redirecionamento do Google, confere o state e
+// envia o código de autorização (ou o erro) para o canal
+func callbackHandler(state string, results chan<- authResult) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+
+		query := r.URL.Query()
+		if subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(state)) != 1 {
+			// Pode ser outra aba ou um acesso indevido; continua aguardando
+			http.Error(w, "State inválido. Use o link mais recente exibido no terminal.", http.StatusBadRequest)
+			return
+		}
+
+		var result authResult
+		switch {
+		case query.Get("error") != "":
+			result.err = fmt.Errorf("o Google recusou a autorização: %s", query.Get("error"))
+		case query.Get("code") == "":
+			result.err = fmt.Errorf("redirecionamento sem código de autorização")
+		default:
+			result.code = query.Get("code")
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if result.err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, "<h1>Autorização não concluída</h1><p>Volte ao terminal para ver os detalhes.</p>")
+		} else {
+			fmt.Fprint(w, "<h1>Autorização concluída!</h1><p>Você já pode fechar esta janela.</p>")
+		}
+
+		select {
+		case results <- result:
+		default:
+		}
+	})
+}
+
+// randomState gera um valor imprevisível para o parâmetro state
+func randomState() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// openBrowser tenta abrir a URL no navegador padrão do sistema
+func openBrowser(url string) error {
+	switch runtime.GOOS {
+	case "darwin":
+		return exec.Command("open", url).Start()
+	case "windows":
+		return exec.Command("rundll32", "url.dll,FileProtocolHandler", url).Start()
+	default:
+		return exec.Command("xdg-open", url).Start()
+	}
+}
+
 // saveToken salva o token em um arquivo
 func saveToken(path string, token *oauth2.Token) {
 	fmt.Printf("Salvando token em: %s\n", path)
@@ -81,7 +212,11 @@ func saveToken(path string, token *oauth2.Token) {
 	}
 
 	// Salva o token com permissões restritas (apenas o usuário pode ler)
-	if err := ioutil.WriteFile(path, tokenJSON, 0600); err != nil {
+	if err := os.WriteFile(path, tokenJSON, 0600); err != nil {
 		log.Fatalf("Não foi possível salvar o token: %v", err)
 	}
+	// WriteFile não altera as permissões de um arquivo que já existia
+	if err := os.Chmod(path, 0600); err != nil {
+		log.Fatalf("Não foi possível restringir as permissões do token: %v", err)
+	}
 }
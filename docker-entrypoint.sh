@@ -8,8 +8,14 @@ if [ -z "$GOOGLE_DRIVE_FOLDER_ID" ]; then
   echo "Configure esta variável no seu ambiente de deploy."
 fi
 
+# Verificar a chave da conta de serviço, quando configurada
+if [ -n "$GOOGLE_SERVICE_ACCOUNT_FILE" ] && [ ! -f "$GOOGLE_SERVICE_ACCOUNT_FILE" ]; then
+  echo "[AVISO] Chave da conta de serviço não encontrada em $GOOGLE_SERVICE_ACCOUNT_FILE"
+  echo "As funcionalidades do Google Drive não funcionarão corretamente."
+fi
+
 # Verificar se as credenciais do Google Drive existem
-if [ ! -f "$CONFIG_DIR/credentials.json" ]; then
+if [ -z "$GOOGLE_SERVICE_ACCOUNT_FILE" ] && [ ! -f "$CONFIG_DIR/credentials.json" ]; then
   echo "[AVISO] Arquivo de credenciais não encontrado em $CONFIG_DIR/credentials.json"
   echo "As funcionalidades do Google Drive não funcionarão corretamente."
   echo "Monte um volume com o arquivo de credenciais ou use secrets."
@@ -19,7 +25,7 @@ if [ ! -f "$CONFIG_DIR/credentials.json" ]; then
 fi
 
 # Verificar se o credentials.json já existe
-if [ ! -f "$CONFIG_DIR/credentials.json" ]; then
+if [ -z "$GOOGLE_SERVICE_ACCOUNT_FILE" ] && [ ! -f "$CONFIG_DIR/credentials.json" ]; then
   echo "[AVISO] Arquivo de credentials.json não encontrado em $CONFIG_DIR/credentials.json"
   echo "As funcionalidades do Google Drive não funcionarão corretamente."
   echo "Monte um volume com o arquivo de credentials.json ou use secrets."
@@ -0,0 +1,361 @@
+// Package config carrega e valida as configurações do servidor.
+//
+// As configurações são lidas, em ordem de prioridade crescente, dos valores
+// padrão, de um arquivo YAML opcional e das variáveis de ambiente (incluindo
+// as definidas no arquivo .env).
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Nome do arquivo YAML procurado em ConfigDir quando CONFIG_FILE não é definido
+const defaultConfigFileName = "config.yaml"
+
+// Config reúne todas as configurações do servidor
+type Config struct {
+	Port       string `yaml:"port"`
+	ConfigDir  string `yaml:"config_dir"` // credentials.json e token.json
+	DataDir    string `yaml:"data_dir"`   // textos alternativos, snapshots e cache
+	AdminToken string `yaml:"admin_token"`
+
+	Server     ServerConfig     `yaml:"server"`
+	Drive      DriveConfig      `yaml:"drive"`
+	Gallery    GalleryConfig    `yaml:"gallery"`
+	S3         S3Config         `yaml:"s3"`
+	ImageCache ImageCacheConfig `yaml:"image_cache"`
+	RSVP       RSVPConfig       `yaml:"rsvp"`
+
+	// Arquivo YAML de onde as configurações foram lidas, se houver
+	File string `yaml:"-"`
+}
+
+// ServerConfig contém os timeouts do servidor HTTP. ShutdownTimeout limita a
+// espera pelas requisições em andamento quando o servidor é encerrado.
+// TrustProxy indica que o servidor está atrás de um proxy reverso (Render) e
+// que o IP do cliente vem do X-Forwarded-For.
+type ServerConfig struct {
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	WriteTimeout      time.Duration `yaml:"write_timeout"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout"`
+	TrustProxy        bool          `yaml:"trust_proxy"`
+}
+
+// DriveConfig contém as configurações da integração com o Google Drive
+type DriveConfig struct {
+	FolderID           string        `yaml:"folder_id"`
+	CacheTTL           time.Duration `yaml:"cache_ttl"`
+	SyncInterval       time.Duration `yaml:"sync_interval"`
+	WebhookURL         string        `yaml:"webhook_url"`
+	WebhookToken       string        `yaml:"webhook_token"`
+	ServiceAccountFile string        `yaml:"service_account_file"`
+	Subject            string        `yaml:"subject"`
+}
+
+// GalleryConfig contém as configurações da galeria em diretório local
+type GalleryConfig struct {
+	Dir string `yaml:"dir"`
+}
+
+// S3Config contém os parâmetros de acesso a um bucket compatível com S3
+type S3Config struct {
+	Endpoint        string        `yaml:"endpoint"` // ex: https://s3.amazonaws.com ou http://localhost:9000
+	Region          string        `yaml:"region"`
+	Bucket          string        `yaml:"bucket"`
+	Prefix          string        `yaml:"prefix"`
+	AccessKeyID     string        `yaml:"access_key_id"`
+	SecretAccessKey string        `yaml:"secret_access_key"`
+	CacheTTL        time.Duration `yaml:"cache_ttl"`
+}
+
+// ImageCacheConfig contém as configurações do cache em disco do proxy.
+// MaxMB igual a 0 desabilita o cache.
+type ImageCacheConfig struct {
+	Dir   string `yaml:"dir"`
+	MaxMB int64  `yaml:"max_mb"`
+}
+
+// RSVPConfig contém as configurações da confirmação de presença.
+// Sem FormFile, o servidor usa o questionário padrão embutido; sem Deadline,
+// as confirmações ficam abertas.
+type RSVPConfig struct {
+	FormFile string `yaml:"form_file"`
+	Deadline string `yaml:"deadline"` // ex: 2026-11-30 ou 2026-11-30T18:00:00-03:00
+}
+
+// brasiliaTime é o fuso das datas de RSVP_DEADLINE informadas sem horário
+var brasiliaTime = time.FixedZone("BRT", -3*60*60)
+
+// DeadlineTime retorna o fim do prazo de confirmação, ou o instante zero
+// quando não há prazo. Uma data sem horário vale até o fim do dia, no
+// horário de Brasília.
+func (rc RSVPConfig) DeadlineTime() (time.Time, error) {
+	if rc.Deadline == "" {
+		return time.Time{}, nil
+	}
+	if deadline, err := time.Parse(time.RFC3339, rc.Deadline); err == nil {
+		return deadline, nil
+	}
+	day, err := time.ParseInLocation("2006-01-02", rc.Deadline, brasiliaTime)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("RSVP_DEADLINE inválido (%q): use uma data como 2026-11-30 ou 2026-11-30T18:00:00-03:00", rc.Deadline)
+	}
+	return day.Add(24*time.Hour - time.Second), nil
+}
+
+// Default retorna as configurações padrão
+func Default() *Config {
+	return &Config{
+		Port:      "3000",
+		ConfigDir: "./config",
+		DataDir:   "./data",
+		Server: ServerConfig{
+			ReadHeaderTimeout: 10 * time.Second,
+			WriteTimeout:      2 * time.Minute,
+			IdleTimeout:       2 * time.Minute,
+			ShutdownTimeout:   25 * time.Second,
+		},
+		Drive: DriveConfig{
+			CacheTTL:     15 * time.Minute,
+			SyncInterval: time.Minute,
+		},
+		S3: S3Config{
+			CacheTTL: 5 * time.Minute,
+		},
+		ImageCache: ImageCacheConfig{
+			MaxMB: 500,
+		},
+	}
+}
+
+// Load carrega o arquivo .env, o arquivo YAML (CONFIG_FILE ou
+// <CONFIG_DIR>/config.yaml) e as variáveis de ambiente, e valida o resultado
+func Load() (*Config, error) {
+	// Carrega as variáveis de ambiente do arquivo .env, sem sobrescrever as já definidas
+	if err := godotenv.Load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("erro ao ler o arquivo .env: %v", err)
+	}
+
+	cfg := Default()
+	if dir := os.Getenv("CONFIG_DIR"); dir != "" {
+		cfg.ConfigDir = dir
+	}
+
+	file := os.Getenv("CONFIG_FILE")
+	required := file != ""
+	if file == "" {
+		file = filepath.Join(cfg.ConfigDir, defaultConfigFileName)
+	}
+	if err := cfg.loadFile(file, required); err != nil {
+		return nil, err
+	}
+
+	// Reporta de uma vez os valores ilegíveis e os inválidos
+	if err := errors.Join(cfg.loadEnv(), cfg.Validate()); err != nil {
+		return nil, fmt.Errorf("configuração inválida:\n%w", err)
+	}
+
+	cfg.applyDefaults()
+	return cfg, nil
+}
+
+// loadFile lê o arquivo YAML. Quando o arquivo não foi pedido explicitamente,
+// a ausência dele não é um erro.
+func (c *Config) loadFile(file string, required bool) error {
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) && !required {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("erro ao ler o arquivo de configuração: %v", err)
+	}
+
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return fmt.Errorf("erro ao analisar o arquivo de configuração %s: %v", file, err)
+	}
+
+	c.File = file
+	log.Printf("Configurações carregadas de %s", file)
+	return nil
+}
+
+// loadEnv sobrescreve as configurações com as variáveis de ambiente definidas
+func (c *Config) loadEnv() error {
+	var errs []error
+
+	setString := func(name string, target *string) {
+		if value := os.Getenv(name); value != "" {
+			*target = value
+		}
+	}
+	setDuration := func(name string, target *time.Duration) {
+		if value := os.Getenv(name); value != "" {
+			parsed, err := time.ParseDuration(value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s inválido (%q): use o formato do Go, ex: 30s, 10m, 1h", name, value))
+				return
+			}
+			*target = parsed
+		}
+	}
+	setInt := func(name string, target *int64) {
+		if value := os.Getenv(name); value != "" {
+			parsed, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s inválido (%q): deve ser um número inteiro", name, value))
+				return
+			}
+			*target = parsed
+		}
+	}
+	setBool := func(name string, target *bool) {
+		if value := os.Getenv(name); value != "" {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s inválido (%q): use true ou false", name, value))
+				return
+			}
+			*target = parsed
+		}
+	}
+
+	setString("PORT", &c.Port)
+	setString("CONFIG_DIR", &c.ConfigDir)
+	setString("DATA_DIR", &c.DataDir)
+	setString("ADMIN_TOKEN", &c.AdminToken)
+
+	setDuration("SERVER_READ_HEADER_TIMEOUT", &c.Server.ReadHeaderTimeout)
+	setDuration("SERVER_WRITE_TIMEOUT", &c.Server.WriteTimeout)
+	setDuration("SERVER_IDLE_TIMEOUT", &c.Server.IdleTimeout)
+	setDuration("SERVER_SHUTDOWN_TIMEOUT", &c.Server.ShutdownTimeout)
+	setBool("SERVER_TRUST_PROXY", &c.Server.TrustProxy)
+
+	setString("GOOGLE_DRIVE_FOLDER_ID", &c.Drive.FolderID)
+	setDuration("DRIVE_CACHE_TTL", &c.Drive.CacheTTL)
+	setDuration("DRIVE_SYNC_INTERVAL", &c.Drive.SyncInterval)
+	setString("DRIVE_WEBHOOK_URL", &c.Drive.WebhookURL)
+	setString("DRIVE_WEBHOOK_TOKEN", &c.Drive.WebhookToken)
+	setString("GOOGLE_SERVICE_ACCOUNT_FILE", &c.Drive.ServiceAccountFile)
+	setString("GOOGLE_DRIVE_SUBJECT", &c.Drive.Subject)
+
+	setString("GALLERY_DIR", &c.Gallery.Dir)
+
+	setString("S3_ENDPOINT", &c.S3.Endpoint)
+	setString("S3_REGION", &c.S3.Region)
+	setString("S3_BUCKET", &c.S3.Bucket)
+	setString("S3_PREFIX", &c.S3.Prefix)
+	setString("S3_ACCESS_KEY_ID", &c.S3.AccessKeyID)
+	setString("S3_SECRET_ACCESS_KEY", &c.S3.SecretAccessKey)
+	setDuration("S3_CACHE_TTL", &c.S3.CacheTTL)
+
+	setString("IMAGE_CACHE_DIR", &c.ImageCache.Dir)
+	setInt("IMAGE_CACHE_MAX_MB", &c.ImageCache.MaxMB)
+
+	setString("RSVP_FORM_FILE", &c.RSVP.FormFile)
+	setString("RSVP_DEADLINE", &c.RSVP.Deadline)
+
+	return errors.Join(errs...)
+}
+
+// Validate confere as configurações e retorna todos os problemas encontrados
+func (c *Config) Validate() error {
+	var errs []error
+
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		errs = append(errs, fmt.Errorf("porta inválida (%q): deve ser um número entre 1 e 65535", c.Port))
+	}
+	if c.ConfigDir == "" {
+		errs = append(errs, errors.New("CONFIG_DIR não pode ser vazio"))
+	}
+	if c.DataDir == "" {
+		errs = append(errs, errors.New("DATA_DIR não pode ser vazio"))
+	}
+
+	durations := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"SERVER_READ_HEADER_TIMEOUT", c.Server.ReadHeaderTimeout},
+		{"SERVER_WRITE_TIMEOUT", c.Server.WriteTimeout},
+		{"SERVER_IDLE_TIMEOUT", c.Server.IdleTimeout},
+		{"SERVER_SHUTDOWN_TIMEOUT", c.Server.ShutdownTimeout},
+		{"DRIVE_CACHE_TTL", c.Drive.CacheTTL},
+		{"DRIVE_SYNC_INTERVAL", c.Drive.SyncInterval},
+		{"S3_CACHE_TTL", c.S3.CacheTTL},
+	}
+	for _, d := range durations {
+		if d.value <= 0 {
+			errs = append(errs, fmt.Errorf("%s deve ser maior que zero", d.name))
+		}
+	}
+
+	if c.Drive.Subject != "" && c.Drive.ServiceAccountFile == "" {
+		errs = append(errs, errors.New("GOOGLE_DRIVE_SUBJECT exige GOOGLE_SERVICE_ACCOUNT_FILE"))
+	}
+	if (c.Drive.WebhookURL == "") != (c.Drive.WebhookToken == "") {
+		errs = append(errs, errors.New("DRIVE_WEBHOOK_URL e DRIVE_WEBHOOK_TOKEN devem ser definidos juntos"))
+	}
+	if c.Drive.WebhookURL != "" {
+		if u, err := url.Parse(c.Drive.WebhookURL); err != nil || u.Scheme != "https" || u.Host == "" {
+			errs = append(errs, fmt.Errorf("DRIVE_WEBHOOK_URL inválido (%q): o Drive exige um endereço HTTPS", c.Drive.WebhookURL))
+		}
+	}
+
+	if c.Gallery.Dir != "" {
+		if info, err := os.Stat(c.Gallery.Dir); err != nil || !info.IsDir() {
+			errs = append(errs, fmt.Errorf("GALLERY_DIR (%q) não é um diretório acessível", c.Gallery.Dir))
+		}
+	}
+
+	if c.S3.Bucket != "" && (c.S3.AccessKeyID == "") != (c.S3.SecretAccessKey == "") {
+		errs = append(errs, errors.New("S3_ACCESS_KEY_ID e S3_SECRET_ACCESS_KEY devem ser definidos juntos"))
+	}
+	if c.S3.Endpoint != "" {
+		if u, err := url.Parse(c.S3.Endpoint); err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			errs = append(errs, fmt.Errorf("S3_ENDPOINT inválido (%q): use uma URL como https://s3.amazonaws.com", c.S3.Endpoint))
+		}
+	}
+
+	if c.ImageCache.MaxMB < 0 {
+		errs = append(errs, fmt.Errorf("IMAGE_CACHE_MAX_MB inválido (%d): use 0 para desabilitar o cache", c.ImageCache.MaxMB))
+	}
+
+	if c.RSVP.FormFile != "" {
+		if info, err := os.Stat(c.RSVP.FormFile); err != nil || info.IsDir() {
+			errs = append(errs, fmt.Errorf("RSVP_FORM_FILE (%q) não é um arquivo acessível", c.RSVP.FormFile))
+		}
+	}
+	if _, err := c.RSVP.DeadlineTime(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// applyDefaults preenche os valores que dependem de outros campos
+func (c *Config) applyDefaults() {
+	if c.ImageCache.Dir == "" {
+		c.ImageCache.Dir = filepath.Join(c.DataDir, "image-cache")
+	}
+}
+
+// ConfigPath retorna o caminho de um arquivo dentro de ConfigDir
+func (c *Config) ConfigPath(name string) string {
+	return filepath.Join(c.ConfigDir, name)
+}
+
+// DataPath retorna o caminho de um arquivo dentro de DataDir
+func (c *Config) DataPath(name string) string {
+	return filepath.Join(c.DataDir, name)
+}
@@ -0,0 +1,173 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// configEnv são as variáveis lidas por Load, limpas antes de cada teste para
+// que o ambiente de quem roda os testes não interfira
+var configEnv = []string{
+	"CONFIG_FILE", "PORT", "CONFIG_DIR", "DATA_DIR", "ADMIN_TOKEN",
+	"SERVER_READ_HEADER_TIMEOUT", "SERVER_WRITE_TIMEOUT", "SERVER_IDLE_TIMEOUT", "SERVER_SHUTDOWN_TIMEOUT", "SERVER_TRUST_PROXY",
+	"GOOGLE_DRIVE_FOLDER_ID", "DRIVE_CACHE_TTL", "DRIVE_SYNC_INTERVAL", "DRIVE_WEBHOOK_URL", "DRIVE_WEBHOOK_TOKEN",
+	"GOOGLE_SERVICE_ACCOUNT_FILE", "GOOGLE_DRIVE_SUBJECT", "GALLERY_DIR",
+	"S3_ENDPOINT", "S3_REGION", "S3_BUCKET", "S3_PREFIX", "S3_ACCESS_KEY_ID", "S3_SECRET_ACCESS_KEY", "S3_CACHE_TTL",
+	"IMAGE_CACHE_DIR", "IMAGE_CACHE_MAX_MB", "RSVP_FORM_FILE", "RSVP_DEADLINE",
+}
+
+// clearConfigEnv esvazia as variáveis de configuração e aponta CONFIG_DIR
+// para um diretório vazio
+func clearConfigEnv(t *testing.T) string {
+	t.Helper()
+	for _, name := range configEnv {
+		t.Setenv(name, "")
+	}
+	dir := t.TempDir()
+	t.Setenv("CONFIG_DIR", dir)
+	return dir
+}
+
+// writeConfigFile grava um arquivo de configuração YAML no diretório informado
+func writeConfigFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	file := filepath.Join(dir, name)
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	dir := clearConfigEnv(t)
+	file := writeConfigFile(t, dir, "producao.yaml", `
+port: "4000"
+data_dir: /var/lib/casamento
+drive:
+  folder_id: pasta-do-yaml
+  cache_ttl: 30m
+s3:
+  cache_ttl: 2m
+server:
+  trust_proxy: true
+`)
+	t.Setenv("CONFIG_FILE", file)
+	t.Setenv("PORT", "5000")
+	t.Setenv("DRIVE_CACHE_TTL", "45m")
+	t.Setenv("SERVER_TRUST_PROXY", "false")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		got, want interface{}
+	}{
+		{"variável de ambiente sobre o YAML", cfg.Port, "5000"},
+		{"duração do ambiente sobre o YAML", cfg.Drive.CacheTTL, 45 * time.Minute},
+		{"booleano do ambiente sobre o YAML", cfg.Server.TrustProxy, false},
+		{"YAML sobre o padrão", cfg.Drive.FolderID, "pasta-do-yaml"},
+		{"duração do YAML", cfg.S3.CacheTTL, 2 * time.Minute},
+		{"padrão sem YAML nem ambiente", cfg.Server.WriteTimeout, 2 * time.Minute},
+		{"padrão derivado de outro campo", cfg.ImageCache.Dir, filepath.Join("/var/lib/casamento", "image-cache")},
+		{"arquivo lido", cfg.File, file},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: %v, esperava %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestLoadConfigFileLocation(t *testing.T) {
+	t.Run("config.yaml em CONFIG_DIR", func(t *testing.T) {
+		dir := clearConfigEnv(t)
+		writeConfigFile(t, dir, defaultConfigFileName, "port: \"4000\"\n")
+		cfg, err := Load()
+		if err != nil {
+			t.Fatalf("Load: %v", err)
+		}
+		if cfg.Port != "4000" || cfg.File != filepath.Join(dir, defaultConfigFileName) {
+			t.Errorf("porta %q de %q, esperava o config.yaml de CONFIG_DIR", cfg.Port, cfg.File)
+		}
+	})
+
+	t.Run("sem arquivo usa os padrões", func(t *testing.T) {
+		clearConfigEnv(t)
+		cfg, err := Load()
+		if err != nil {
+			t.Fatalf("Load: %v", err)
+		}
+		if cfg.Port != Default().Port || cfg.File != "" {
+			t.Errorf("porta %q de %q, esperava o padrão sem arquivo", cfg.Port, cfg.File)
+		}
+	})
+
+	t.Run("CONFIG_FILE inexistente", func(t *testing.T) {
+		dir := clearConfigEnv(t)
+		t.Setenv("CONFIG_FILE", filepath.Join(dir, "nao-existe.yaml"))
+		if _, err := Load(); err == nil {
+			t.Error("um CONFIG_FILE pedido explicitamente e ausente deveria ser um erro")
+		}
+	})
+
+	t.Run("YAML inválido", func(t *testing.T) {
+		dir := clearConfigEnv(t)
+		writeConfigFile(t, dir, defaultConfigFileName, "drive: [\n")
+		if _, err := Load(); err == nil {
+			t.Error("um YAML ilegível deveria ser um erro")
+		}
+	})
+}
+
+func TestLoadReportsAllInvalidValues(t *testing.T) {
+	clearConfigEnv(t)
+	t.Setenv("PORT", "0")
+	t.Setenv("DRIVE_CACHE_TTL", "15")
+	t.Setenv("S3_CACHE_TTL", "-1m")
+	t.Setenv("IMAGE_CACHE_MAX_MB", "muito")
+	t.Setenv("SERVER_TRUST_PROXY", "talvez")
+	t.Setenv("RSVP_DEADLINE", "30/11/2026")
+
+	_, err := Load()
+	if err == nil {
+		t.Fatal("esperava erro de configuração inválida")
+	}
+	for _, name := range []string{"porta", "DRIVE_CACHE_TTL", "S3_CACHE_TTL", "IMAGE_CACHE_MAX_MB", "SERVER_TRUST_PROXY", "RSVP_DEADLINE"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("erro não menciona %s:\n%v", name, err)
+		}
+	}
+}
+
+func TestRSVPDeadlineTime(t *testing.T) {
+	tests := []struct {
+		name     string
+		deadline string
+		want     time.Time
+		wantErr  bool
+	}{
+		{"sem prazo", "", time.Time{}, false},
+		{"data vale até o fim do dia em Brasília", "2026-11-30", time.Date(2026, 12, 1, 2, 59, 59, 0, time.UTC), false},
+		{"data e hora com fuso", "2026-11-30T18:00:00-03:00", time.Date(2026, 11, 30, 21, 0, 0, 0, time.UTC), false},
+		{"data e hora em UTC", "2026-11-30T12:00:00Z", time.Date(2026, 11, 30, 12, 0, 0, 0, time.UTC), false},
+		{"formato brasileiro", "30/11/2026", time.Time{}, true},
+		{"data sem fuso", "2026-11-30T18:00:00", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RSVPConfig{Deadline: tt.deadline}.DeadlineTime()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DeadlineTime(%q) erro = %v, esperava erro: %v", tt.deadline, err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("DeadlineTime(%q) = %v, esperava %v", tt.deadline, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/kollinn/casamento-mari-kollinn/internal/service"
+)
+
+// AdminHandler gerencia as operações administrativas da galeria
+type AdminHandler struct {
+	adminToken   string
+	imageSource  service.ImageSource
+	imageHandler *ImageHandler
+}
+
+// NewAdminHandler cria uma nova instância do AdminHandler.
+// Sem adminToken, os endpoints administrativos ficam desabilitados.
+func NewAdminHandler(adminToken string, imageSource service.ImageSource, imageHandler *ImageHandler) *AdminHandler {
+	if adminToken == "" {
+		log.Println("AVISO: ADMIN_TOKEN não configurado, endpoints administrativos desabilitados")
+	}
+	return &AdminHandler{
+		adminToken:   adminToken,
+		imageSource:  imageSource,
+		imageHandler: imageHandler,
+	}
+}
+
+// RequireToken exige o header "Authorization: Bearer <ADMIN_TOKEN>"
+func (h *AdminHandler) RequireToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.adminToken == "" {
+			http.Error(w, "Endpoint administrativo desabilitado", http.StatusForbidden)
+			return
+		}
+
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(h.adminToken)) != 1 {
+			log.Printf("Token administrativo inválido para %s", r.URL.Path)
+			http.Error(w, "Não autorizado", http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RefreshImages força a atualização da lista de imagens da fonte
+func (h *AdminHandler) RefreshImages(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Recebida requisição POST /api/admin/refresh")
+
+	refresher, ok := h.imageSource.(service.Refresher)
+	if !ok {
+		http.Error(w, "A fonte de imagens não suporta atualização", http.StatusNotImplemented)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second)
+	defer cancel()
+
+	if err := refresher.Refresh(ctx); err != nil {
+		log.Printf("Erro ao atualizar imagens: %v", err)
+		http.Error(w, "Erro ao atualizar imagens", http.StatusBadGateway)
+		return
+	}
+
+	// Garante que a próxima resposta de /api/images reflita a nova lista
+	h.imageHandler.InvalidateCache()
+
+	w.Header().Set("Content-Type", "application/json")
+	response := map[string]string{"status": "success"}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Erro ao codificar resposta JSON: %v", err)
+		http.Error(w, "Erro ao processar resposta", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Lista de imagens atualizada com sucesso")
+}
@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/kollinn/casamento-mari-kollinn/internal/service"
+)
+
+// readinessTimeout limita a consulta às fontes que não informam o próprio estado
+const readinessTimeout = 5 * time.Second
+
+// catalogLoadGrace é o tempo dado à primeira listagem de imagens antes de o
+// catálogo ainda não carregado contar como falha
+const catalogLoadGrace = 5 * time.Minute
+
+// probeInterval é o intervalo mínimo entre consultas ao backend feitas pelo
+// /readyz, para que um monitor frequente não gaste a cota da API
+const probeInterval = 30 * time.Second
+
+// HealthHandler responde às verificações de saúde da plataforma
+type HealthHandler struct {
+	imageSource    service.ImageSource
+	altTextService *service.AltTextService
+	startedAt      time.Time
+
+	// Resultado da última consulta ao backend (service.Prober)
+	probeErr   error
+	probedAt   time.Time
+	probeMutex sync.Mutex
+}
+
+// CheckResult é o resultado de uma verificação do /readyz. Warning indica
+// um problema que não impede o funcionamento (ex: catálogo desatualizado).
+type CheckResult struct {
+	OK      bool                  `json:"ok"`
+	Detail  string                `json:"detail,omitempty"`
+	Warning string                `json:"warning,omitempty"`
+	Source  *service.SourceStatus `json:"source,omitempty"`
+}
+
+// ReadinessReport é a resposta do /readyz
+type ReadinessReport struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+// NewHealthHandler cria uma nova instância do HealthHandler
+func NewHealthHandler(imageSource service.ImageSource, altTextService *service.AltTextService) *HealthHandler {
+	return &HealthHandler{
+		imageSource:    imageSource,
+		altTextService: altTextService,
+		startedAt:      time.Now(),
+	}
+}
+
+// Healthz indica apenas que o processo está no ar e respondendo
+func (h *HealthHandler) Healthz(w http.ResponseWriter, r *http.Request) {
+	writeHealthJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// Readyz indica se a galeria está de fato funcionando: fonte de imagens
+// acessível e textos alternativos carregados. Um catálogo carregado mas
+// desatualizado continua sendo servido e só gera um aviso.
+// O estado do Drive é o da última atualização do catálogo; para não depender
+// dela, o backend também é consultado, no máximo a cada probeInterval.
+// Responde 503 quando alguma verificação falha.
+func (h *HealthHandler) Readyz(w http.ResponseWriter, r *http.Request) {
+	report := ReadinessReport{
+		Status: "ready",
+		Checks: map[string]CheckResult{
+			"gallery":  h.checkGallery(r.Context()),
+			"altTexts": h.checkAltTexts(),
+		},
+	}
+
+	status := http.StatusOK
+	for name, check := range report.Checks {
+		if check.Warning != "" {
+			log.Printf("Verificação de prontidão %q com aviso: %s", name, check.Warning)
+		}
+		if !check.OK {
+			log.Printf("Verificação de prontidão %q falhou: %s", name, check.Detail)
+			report.Status = "not_ready"
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	writeHealthJSON(w, status, report)
+}
+
+// checkGallery verifica a fonte de imagens. Sem fonte configurada, o site
+// funciona sem galeria, então a verificação não falha.
+func (h *HealthHandler) checkGallery(ctx context.Context) CheckResult {
+	if h.imageSource == nil {
+		return CheckResult{OK: true, Detail: "nenhuma fonte de imagens configurada"}
+	}
+
+	// Fontes com estado próprio (Drive) respondem pelo catálogo em memória,
+	// complementado por uma consulta barata ao backend
+	if reporter, ok := h.imageSource.(service.StatusReporter); ok {
+		probeErr := h.probe(ctx)
+		status := reporter.Status()
+		result := CheckResult{OK: true, Source: &status}
+		switch {
+		case status.AuthError != "":
+			result.OK, result.Detail = false, "falha de autenticação no Drive"
+		case !status.Loaded && time.Since(h.startedAt) > catalogLoadGrace:
+			result.OK, result.Detail = false, "catálogo de imagens não carregado desde o início do servidor"
+		case !status.Loaded:
+			result.Warning = "catálogo de imagens ainda não carregado"
+		case status.LastError != "":
+			// O catálogo anterior continua sendo servido enquanto o Drive falha
+			result.Warning = "última atualização do Drive falhou; servindo o catálogo anterior"
+		case probeErr != nil:
+			// O catálogo em cache continua sendo servido, como numa atualização que falhou
+			result.Warning = "o Drive não respondeu à verificação: " + probeErr.Error()
+		}
+		return result
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+
+	images, err := h.imageSource.ListImages(ctx)
+	if err != nil {
+		return CheckResult{OK: false, Detail: err.Error()}
+	}
+	return CheckResult{OK: true, Source: &service.SourceStatus{Loaded: true, ImageCount: len(images)}}
+}
+
+// probe consulta o backend quando a fonte implementa service.Prober,
+// reaproveitando o último resultado por probeInterval
+func (h *HealthHandler) probe(ctx context.Context) error {
+	prober, ok := h.imageSource.(service.Prober)
+	if !ok {
+		return nil
+	}
+
+	h.probeMutex.Lock()
+	defer h.probeMutex.Unlock()
+	if !h.probedAt.IsZero() && time.Since(h.probedAt) < probeInterval {
+		return h.probeErr
+	}
+
+	// O resultado é reaproveitado, então não depende do cliente que perguntou
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), readinessTimeout)
+	defer cancel()
+	h.probeErr = prober.Probe(ctx)
+	h.probedAt = time.Now()
+	return h.probeErr
+}
+
+// checkAltTexts verifica se os textos alternativos foram carregados
+func (h *HealthHandler) checkAltTexts() CheckResult {
+	if h.altTextService == nil || !h.altTextService.Ready() {
+		return CheckResult{OK: false, Detail: "textos alternativos não carregados"}
+	}
+	return CheckResult{OK: true}
+}
+
+// writeHealthJSON escreve a resposta sem permitir cache
+func writeHealthJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("Erro ao codificar resposta JSON: %v", err)
+	}
+}
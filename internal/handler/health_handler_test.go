@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/kollinn/casamento-mari-kollinn/internal/service"
+)
+
+// probingSource é uma fonte com estado próprio que também responde ao Probe
+type probingSource struct {
+	*fakeImageSource
+	status   service.SourceStatus
+	probeErr error
+	probes   int
+}
+
+func (s *probingSource) Status() service.SourceStatus {
+	return s.status
+}
+
+func (s *probingSource) Probe(ctx context.Context) error {
+	s.probes++
+	return s.probeErr
+}
+
+func TestCheckGalleryProbesBackend(t *testing.T) {
+	source := &probingSource{fakeImageSource: newFakeImageSource(), status: service.SourceStatus{Loaded: true, ImageCount: 3}}
+	h := NewHealthHandler(source, nil)
+	ctx := context.Background()
+
+	if result := h.checkGallery(ctx); !result.OK || result.Warning != "" {
+		t.Errorf("Drive respondendo: %+v", result)
+	}
+
+	// A consulta é reaproveitada dentro de probeInterval
+	h.checkGallery(ctx)
+	if source.probes != 1 {
+		t.Errorf("%d consultas ao backend em sequência, esperava 1", source.probes)
+	}
+
+	// Com o resultado expirado, uma falha do backend vira aviso: o catálogo
+	// em memória continua sendo servido
+	source.probeErr = errors.New("connection refused")
+	h.probedAt = h.probedAt.Add(-probeInterval)
+	result := h.checkGallery(ctx)
+	if !result.OK || result.Warning == "" || source.probes != 2 {
+		t.Errorf("Drive fora do ar: %+v depois de %d consultas", result, source.probes)
+	}
+
+	// Credenciais recusadas continuam derrubando a verificação
+	source.status.AuthError = "token revogado"
+	if result := h.checkGallery(ctx); result.OK {
+		t.Errorf("credencial recusada: %+v", result)
+	}
+}
@@ -1,17 +1,25 @@
 package handler
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/kollinn/casamento-mari-kollinn/internal/model"
 	"github.com/kollinn/casamento-mari-kollinn/internal/service"
+	"golang.org/x/sync/singleflight"
 )
 
 // ResponseCache guarda a resposta JSON em cache
@@ -20,31 +28,74 @@ type ResponseCache struct {
 	ExpiresAt time.Time
 }
 
+// Limites de paginação de GET /api/images
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+// maxProxyImageBytes limita o tamanho das imagens baixadas pelo proxy
+const maxProxyImageBytes = 50 << 20
+
+// largeImageWidth é a largura usada por ?size=large
+const largeImageWidth = 1200
+
+// sizeLarge é o valor de ?size que pede a imagem ampliada do modal
+const sizeLarge = "large"
+
+// errSourceUnavailable indica que nenhuma fonte de imagens está configurada
+var errSourceUnavailable = errors.New("nenhuma fonte de imagens configurada")
+
+// ImagePage é a resposta paginada de GET /api/images
+type ImagePage struct {
+	Images   []model.DriveImage `json:"images"`
+	Page     int                `json:"page"`
+	PageSize int                `json:"pageSize"`
+	Total    int                `json:"total"`
+	HasMore  bool               `json:"hasMore"`
+}
+
 // ImageHandler gerencia as requisições relacionadas às imagens
 type ImageHandler struct {
-	driveService    *service.DriveService
+	imageSource     service.ImageSource
 	altTextService  *service.AltTextService
+	diskCache       *service.DiskImageCache
+	fetchGroup      singleflight.Group
 	responseCache   *ResponseCache
 	responseMutex   sync.RWMutex
 	cacheExpiration time.Duration
 }
 
-// NewImageHandler cria uma nova instância do ImageHandler
-func NewImageHandler(driveService *service.DriveService, altTextService *service.AltTextService) *ImageHandler {
-	if driveService == nil {
-		log.Fatal("DriveService não pode ser nil")
+// NewImageHandler cria uma nova instância do ImageHandler.
+// imageSource pode ser nil; nesse caso os endpoints de imagens respondem 503.
+// diskCache também é opcional.
+func NewImageHandler(imageSource service.ImageSource, altTextService *service.AltTextService, diskCache *service.DiskImageCache) *ImageHandler {
+	if imageSource == nil {
+		log.Println("AVISO: Nenhuma fonte de imagens configurada, galeria indisponível")
 	}
 	return &ImageHandler{
-		driveService:    driveService,
+		imageSource:     imageSource,
 		altTextService:  altTextService,
+		diskCache:       diskCache,
 		cacheExpiration: 5 * time.Minute, // Cache expira em 5 minutos
 	}
 }
 
-// GetImages retorna a lista de imagens disponíveis no Drive
+// GetImages retorna a lista de imagens disponíveis na fonte configurada
 func (h *ImageHandler) GetImages(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Recebida requisição GET /api/images")
 
+	if !h.checkSource(w) {
+		return
+	}
+
+	// Com parâmetros de paginação, responde apenas a página solicitada
+	query := r.URL.Query()
+	if query.Has("page") || query.Has("pageSize") {
+		h.getImagesPage(w, r)
+		return
+	}
+
 	// Verifica se temos cache válido
 	h.responseMutex.RLock()
 	if h.responseCache != nil && time.Now().Before(h.responseCache.ExpiresAt) {
@@ -61,7 +112,7 @@ func (h *ImageHandler) GetImages(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
 	defer cancel()
 
-	images, err := h.driveService.GetDriveImages(ctx)
+	images, err := h.imageSource.ListImages(ctx)
 	if err != nil {
 		log.Printf("Erro ao buscar imagens: %v", err)
 		http.Error(w, "Erro ao buscar imagens", http.StatusInternalServerError)
@@ -100,6 +151,170 @@ func (h *ImageHandler) GetImages(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Resposta enviada com sucesso")
 }
 
+// getImagesPage responde uma página da lista de imagens (?page=1&pageSize=50)
+func (h *ImageHandler) getImagesPage(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	images, err := h.imageSource.ListImages(ctx)
+	if err != nil {
+		log.Printf("Erro ao buscar imagens: %v", err)
+		http.Error(w, "Erro ao buscar imagens", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeImagePage(w, r, images)
+}
+
+// writeImagePage recorta e envia a página solicitada de uma lista de imagens
+func (h *ImageHandler) writeImagePage(w http.ResponseWriter, r *http.Request, images []model.DriveImage) {
+	page, err := parsePositiveInt(r.URL.Query().Get("page"), 1)
+	if err != nil {
+		http.Error(w, "Parâmetro page inválido", http.StatusBadRequest)
+		return
+	}
+	pageSize, err := parsePositiveInt(r.URL.Query().Get("pageSize"), defaultPageSize)
+	if err != nil {
+		http.Error(w, "Parâmetro pageSize inválido", http.StatusBadRequest)
+		return
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+
+	// Recorta a página solicitada
+	start := (page - 1) * pageSize
+	if start > len(images) {
+		start = len(images)
+	}
+	end := start + pageSize
+	if end > len(images) {
+		end = len(images)
+	}
+	pageImages := append([]model.DriveImage{}, images[start:end]...)
+
+	// Enriquece as imagens com textos alternativos personalizados
+	if h.altTextService != nil {
+		pageImages = h.altTextService.EnrichImagesWithAltText(pageImages)
+	}
+
+	log.Printf("Retornando página %d (%d de %d imagens)", page, len(pageImages), len(images))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+
+	response := ImagePage{
+		Images:   pageImages,
+		Page:     page,
+		PageSize: pageSize,
+		Total:    len(images),
+		HasMore:  end < len(images),
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Erro ao codificar resposta JSON: %v", err)
+		http.Error(w, "Erro ao processar resposta", http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetAlbums retorna os álbuns (subpastas) da galeria
+func (h *ImageHandler) GetAlbums(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Recebida requisição GET /api/albums")
+
+	if !h.checkSource(w) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	images, err := h.imageSource.ListImages(ctx)
+	if err != nil {
+		log.Printf("Erro ao buscar imagens: %v", err)
+		http.Error(w, "Erro ao buscar álbuns", http.StatusInternalServerError)
+		return
+	}
+
+	albums := service.GroupAlbums(images)
+	if albums == nil {
+		albums = []model.Album{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	if err := json.NewEncoder(w).Encode(albums); err != nil {
+		log.Printf("Erro ao codificar resposta JSON: %v", err)
+		http.Error(w, "Erro ao processar resposta", http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetAlbumImages retorna as imagens de um álbum. Aceita os mesmos
+// parâmetros de paginação de GET /api/images.
+func (h *ImageHandler) GetAlbumImages(w http.ResponseWriter, r *http.Request) {
+	albumID := mux.Vars(r)["id"]
+
+	log.Printf("Recebida requisição GET /api/albums/%s/images", albumID)
+
+	if !h.checkSource(w) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	images, err := h.imageSource.ListImages(ctx)
+	if err != nil {
+		log.Printf("Erro ao buscar imagens: %v", err)
+		http.Error(w, "Erro ao buscar imagens", http.StatusInternalServerError)
+		return
+	}
+
+	var albumImages []model.DriveImage
+	for _, image := range images {
+		if image.AlbumID == albumID {
+			albumImages = append(albumImages, image)
+		}
+	}
+
+	if len(albumImages) == 0 {
+		log.Printf("Álbum %s não encontrado", albumID)
+		http.Error(w, "Álbum não encontrado", http.StatusNotFound)
+		return
+	}
+
+	query := r.URL.Query()
+	if query.Has("page") || query.Has("pageSize") {
+		h.writeImagePage(w, r, albumImages)
+		return
+	}
+
+	// Enriquece as imagens com textos alternativos personalizados
+	if h.altTextService != nil {
+		albumImages = h.altTextService.EnrichImagesWithAltText(albumImages)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	if err := json.NewEncoder(w).Encode(albumImages); err != nil {
+		log.Printf("Erro ao codificar resposta JSON: %v", err)
+		http.Error(w, "Erro ao processar resposta", http.StatusInternalServerError)
+		return
+	}
+}
+
+// parsePositiveInt converte um parâmetro de query em inteiro positivo
+func parsePositiveInt(value string, defaultValue int) (int, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("valor inválido: %q", value)
+	}
+	return n, nil
+}
+
 // GetImageURL retorna uma URL temporária para acessar uma imagem específica
 func (h *ImageHandler) GetImageURL(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -130,13 +345,19 @@ func (h *ImageHandler) GetImageURL(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Resposta enviada com sucesso")
 }
 
-// ProxyImage atua como um proxy para a imagem do Google Drive
+// ProxyImage atua como um proxy para a imagem armazenada na fonte configurada.
+// Com ?w=<largura> (ou ?size=large), a imagem original é baixada uma vez e
+// redimensionada no servidor, em WebP ou JPEG conforme o header Accept. A
+// largura é arredondada para uma de service.ImageWidths.
+// As imagens ficam no cache em disco, que é consultado antes da fonte para
+// que visualizações repetidas não dependam do Google.
 func (h *ImageHandler) ProxyImage(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	imageID := vars["id"]
 	size := r.URL.Query().Get("size")
+	widthParam := r.URL.Query().Get("w")
 
-	log.Printf("Recebida requisição GET /api/images/%s/proxy?size=%s", imageID, size)
+	log.Printf("Recebida requisição GET /api/images/%s/proxy?size=%s&w=%s", imageID, size, widthParam)
 
 	if imageID == "" {
 		log.Printf("ID da imagem não fornecido")
@@ -144,104 +365,322 @@ func (h *ImageHandler) ProxyImage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Determina qual imagem buscar (thumbnail ou alta resolução)
-	var err error
-	var imageURL string
+	width := 0
+	if widthParam != "" {
+		parsed, err := strconv.Atoi(widthParam)
+		if err != nil || parsed < 1 {
+			http.Error(w, "Parâmetro w inválido", http.StatusBadRequest)
+			return
+		}
+		// Larguras fora da lista viram a mais próxima acima, para que não se
+		// possa gerar uma variante nova por requisição
+		width = service.SnapImageWidth(parsed)
+	} else if size == sizeLarge {
+		width = largeImageWidth
+	}
 
-	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second)
 	defer cancel()
 
-	// Busca a imagem no cache de Drive
-	images, err := h.driveService.GetDriveImages(ctx)
-	if err != nil {
-		log.Printf("Erro ao buscar imagens: %v", err)
-		http.Error(w, "Erro ao buscar imagem", http.StatusInternalServerError)
-		return
+	variant := "thumbnail"
+	format := ""
+	if width > 0 {
+		// A mesma URL responde em formatos diferentes conforme o navegador
+		format = service.NegotiateImageFormat(r.Header.Get("Accept"))
+		variant = service.ImageVariantName(width, format)
+		w.Header().Add("Vary", "Accept")
 	}
 
-	// Encontra a imagem específica pelo ID
-	var thumbnailLink string
-	for _, img := range images {
-		if img.ID == imageID {
-			thumbnailLink = img.ThumbnailLink
-			break
+	// Com os metadados da fonte, respondemos 304 sem carregar a imagem. A
+	// versão do original também invalida o cache em disco quando a foto é
+	// substituída na fonte.
+	var etag, version string
+	var modTime time.Time
+	if h.imageSource != nil {
+		image, err := h.imageSource.GetImage(ctx, imageID)
+		if errors.Is(err, service.ErrImageNotFound) {
+			log.Printf("Imagem com ID %s não encontrada", imageID)
+			http.Error(w, "Imagem não encontrada", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			// A fonte pode estar fora do ar; o cache em disco ainda pode responder
+			log.Printf("Aviso: metadados da imagem %s indisponíveis: %v", imageID, err)
+		} else {
+			version = imageVersion(image)
+			etag = imageETag(version, variant)
+			modTime = image.ModifiedTime
+		}
+	}
+	if etag != "" {
+		w.Header().Set("ETag", etag)
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
 		}
 	}
 
-	if thumbnailLink == "" {
+	var data []byte
+	var contentType string
+	var err error
+	if width > 0 {
+		data, contentType, err = h.loadResized(ctx, imageID, version, width, format)
+	} else {
+		data, contentType, err = h.loadImage(ctx, imageID, version, service.ImageSizeThumbnail, variant)
+	}
+
+	if errors.Is(err, service.ErrImageNotFound) {
 		log.Printf("Imagem com ID %s não encontrada", imageID)
 		http.Error(w, "Imagem não encontrada", http.StatusNotFound)
 		return
 	}
+	if errors.Is(err, errSourceUnavailable) {
+		http.Error(w, "Galeria indisponível", http.StatusServiceUnavailable)
+		return
+	}
+	if errors.Is(err, service.ErrImageTooLarge) {
+		log.Printf("Imagem %s não redimensionada: %v", imageID, err)
+		http.Error(w, "Imagem grande demais para redimensionar", http.StatusUnprocessableEntity)
+		return
+	}
+	if err != nil {
+		log.Printf("Erro ao buscar imagem: %v", err)
+		http.Error(w, "Erro ao buscar imagem", http.StatusInternalServerError)
+		return
+	}
+
+	// Sem metadados, o ETag é derivado do próprio conteúdo
+	if etag == "" {
+		sum := sha256.Sum256(data)
+		w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	}
+
+	writeImage(w, r, data, contentType, modTime)
+}
+
+// imageVersion identifica o conteúdo do arquivo original pelo checksum ou,
+// sem ele, pela data de modificação. Retorna "" se nenhum for conhecido.
+func imageVersion(image *model.DriveImage) string {
+	if image.Checksum != "" {
+		return image.Checksum
+	}
+	if image.ModifiedTime.IsZero() {
+		return ""
+	}
+	return strconv.FormatInt(image.ModifiedTime.UnixNano(), 36)
+}
 
-	// Modifica o tamanho da imagem conforme solicitado
-	if size == "large" {
-		// Substitui =s220 por =s1000 para imagens maiores
-		if strings.Contains(thumbnailLink, "=s") {
-			imageURL = strings.Replace(thumbnailLink, "=s220", "=s1000", 1)
+// imageETag gera um ETag forte a partir da versão do arquivo original e da
+// variante servida
+func imageETag(version, variant string) string {
+	if version == "" {
+		return ""
+	}
+	return `"` + version + "-" + variant + `"`
+}
+
+// etagMatches verifica se o If-None-Match contém o ETag informado
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// proxiedImage é o resultado compartilhado entre downloads simultâneos
+type proxiedImage struct {
+	data        []byte
+	contentType string
+}
+
+// coalesce executa fn uma única vez para requisições simultâneas com a
+// mesma chave; todas recebem o mesmo resultado. O contexto passado a fn
+// não é cancelado quando o primeiro chamador desiste.
+func (h *ImageHandler) coalesce(ctx context.Context, key string, fn func(ctx context.Context) ([]byte, string, error)) ([]byte, string, error) {
+	result, err, shared := h.fetchGroup.Do(key, func() (interface{}, error) {
+		fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 60*time.Second)
+		defer cancel()
+		data, contentType, err := fn(fetchCtx)
+		return proxiedImage{data: data, contentType: contentType}, err
+	})
+	if shared {
+		log.Printf("Download de %s compartilhado entre requisições simultâneas", key)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	image := result.(proxiedImage)
+	return image.data, image.contentType, nil
+}
+
+// loadResized retorna a variante redimensionada da imagem no formato pedido,
+// gerando-a quando ainda não está no cache em disco. Fontes com miniaturas
+// sob medida (Drive) fornecem as larguras pequenas sem baixar o original, e
+// também substituem um original grande demais para decodificar.
+func (h *ImageHandler) loadResized(ctx context.Context, imageID, version string, width int, format string) ([]byte, string, error) {
+	variant := service.ImageVariantName(width, format)
+	if data, contentType, ok := h.readDiskCache(imageID, variant, version); ok {
+		return data, contentType, nil
+	}
+
+	if h.imageSource == nil {
+		return nil, "", errSourceUnavailable
+	}
+	thumbnails, hasThumbnails := h.imageSource.(service.ThumbnailSource)
+
+	return h.coalesce(ctx, imageID+"|"+variant+"|"+version, func(ctx context.Context) ([]byte, string, error) {
+		var source []byte
+		var err error
+		if hasThumbnails && width <= service.ThumbnailMaxWidth {
+			source, err = h.loadThumbnail(ctx, thumbnails, imageID, width)
 		} else {
-			imageURL = thumbnailLink
+			source, _, err = h.loadImage(ctx, imageID, version, service.ImageSizeOriginal, "original")
 		}
-	} else {
-		// Usa o thumbnailLink original
-		imageURL = thumbnailLink
+		if err != nil {
+			return nil, "", err
+		}
+
+		data, err := service.ResizeImage(ctx, source, width, format)
+		if errors.Is(err, service.ErrImageTooLarge) && hasThumbnails {
+			log.Printf("Original da imagem %s grande demais (%v), usando a miniatura da fonte", imageID, err)
+			source, err = h.loadThumbnail(ctx, thumbnails, imageID, width)
+			if err == nil {
+				data, err = service.ResizeImage(ctx, source, width, format)
+			}
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		log.Printf("Imagem %s redimensionada para %s (%d bytes)", imageID, variant, len(data))
+
+		h.writeDiskCache(imageID, variant, version, data, format)
+		return data, format, nil
+	})
+}
+
+// loadThumbnail baixa da fonte a miniatura com a largura informada
+func (h *ImageHandler) loadThumbnail(ctx context.Context, thumbnails service.ThumbnailSource, imageID string, width int) ([]byte, error) {
+	body, _, err := thumbnails.OpenThumbnail(ctx, imageID, width)
+	if err != nil {
+		return nil, err
 	}
+	defer body.Close()
+	return readImageBody(body, imageID)
+}
 
-	log.Printf("Fazendo proxy para URL: %s", imageURL)
+// loadImage retorna os bytes da imagem no tamanho pedido, consultando o
+// cache em disco antes da fonte de imagens. version é a versão atual do
+// original, ou "" se desconhecida. Originais não entram no cache em disco:
+// algumas dezenas de arquivos de 20 MB descartariam todas as miniaturas, e
+// as variantes geradas a partir deles já ficam guardadas.
+func (h *ImageHandler) loadImage(ctx context.Context, imageID, version, size, variant string) ([]byte, string, error) {
+	cacheable := size != service.ImageSizeOriginal
+	if cacheable {
+		if data, contentType, ok := h.readDiskCache(imageID, variant, version); ok {
+			return data, contentType, nil
+		}
+	}
 
-	// Configura um cliente HTTP com timeout
-	client := &http.Client{
-		Timeout: 30 * time.Second,
+	if h.imageSource == nil {
+		return nil, "", errSourceUnavailable
 	}
 
-	// Faz a requisição para o Google Drive
-	req, err := http.NewRequestWithContext(ctx, "GET", imageURL, nil)
+	return h.coalesce(ctx, imageID+"|"+variant+"|"+version, func(ctx context.Context) ([]byte, string, error) {
+		body, contentType, err := h.imageSource.OpenImage(ctx, imageID, size)
+		if err != nil {
+			return nil, "", err
+		}
+		defer body.Close()
+
+		data, err := readImageBody(body, imageID)
+		if err != nil {
+			return nil, "", err
+		}
+
+		if cacheable {
+			h.writeDiskCache(imageID, variant, version, data, contentType)
+		}
+		return data, contentType, nil
+	})
+}
+
+// readImageBody lê os bytes de uma imagem da fonte até maxProxyImageBytes
+func readImageBody(body io.Reader, imageID string) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(body, maxProxyImageBytes+1))
 	if err != nil {
-		log.Printf("Erro ao criar requisição: %v", err)
-		http.Error(w, "Erro interno", http.StatusInternalServerError)
-		return
+		return nil, fmt.Errorf("erro ao ler imagem: %v", err)
+	}
+	if len(data) > maxProxyImageBytes {
+		return nil, fmt.Errorf("imagem %s maior que o limite de %d bytes", imageID, maxProxyImageBytes)
+	}
+	return data, nil
+}
+
+// readDiskCache lê uma variante da imagem do cache em disco, se existir
+func (h *ImageHandler) readDiskCache(imageID, variant, version string) ([]byte, string, bool) {
+	if h.diskCache == nil {
+		return nil, "", false
 	}
 
-	// Adiciona headers para evitar problemas de CORS
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) Chrome/100.0.1000.0")
+	file, contentType, ok := h.diskCache.Get(imageID, variant, version)
+	if !ok {
+		return nil, "", false
+	}
+	defer file.Close()
 
-	// Faz a requisição e obtém resposta
-	resp, err := client.Do(req)
+	data, err := io.ReadAll(file)
 	if err != nil {
-		log.Printf("Erro ao buscar imagem: %v", err)
-		http.Error(w, "Erro ao buscar imagem", http.StatusInternalServerError)
-		return
+		log.Printf("Aviso: erro ao ler imagem do cache: %v", err)
+		return nil, "", false
 	}
-	defer resp.Body.Close()
 
-	// Verifica se a resposta foi bem-sucedida
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("Erro do Google Drive: %s", resp.Status)
-		http.Error(w, "Erro ao buscar imagem", resp.StatusCode)
+	log.Printf("Imagem %s (%s) servida do cache em disco", imageID, variant)
+	return data, contentType, true
+}
+
+// writeDiskCache guarda uma variante da imagem no cache em disco
+func (h *ImageHandler) writeDiskCache(imageID, variant, version string, data []byte, contentType string) {
+	if h.diskCache == nil {
 		return
 	}
-
-	// Copia os headers relevantes da resposta
-	contentType := resp.Header.Get("Content-Type")
-	if contentType != "" {
-		w.Header().Set("Content-Type", contentType)
-	} else {
-		w.Header().Set("Content-Type", "image/jpeg")
+	if err := h.diskCache.Put(imageID, variant, version, data, contentType); err != nil {
+		log.Printf("Aviso: %v", err)
 	}
+}
+
+// writeImage envia os bytes da imagem com os headers de cache do navegador.
+// http.ServeContent trata If-None-Match, If-Modified-Since e Range.
+func writeImage(w http.ResponseWriter, r *http.Request, data []byte, contentType string, modTime time.Time) {
+	w.Header().Set("Content-Type", contentType)
 
 	// Define headers de cache
 	w.Header().Set("Cache-Control", "public, max-age=7200") // Cache de 2 horas
 
-	// Copia a imagem para a resposta
-	_, err = io.Copy(w, resp.Body)
-	if err != nil {
-		log.Printf("Erro ao copiar resposta: %v", err)
-		// Não podemos fazer mais nada aqui pois já começamos a escrever a resposta
-	}
+	http.ServeContent(w, r, "", modTime, bytes.NewReader(data))
 
 	log.Printf("Imagem enviada com sucesso")
 }
 
+// InvalidateCache descarta a resposta JSON em cache de GET /api/images
+func (h *ImageHandler) InvalidateCache() {
+	h.responseMutex.Lock()
+	h.responseCache = nil
+	h.responseMutex.Unlock()
+}
+
+// checkSource responde com 503 quando nenhuma fonte de imagens está configurada
+func (h *ImageHandler) checkSource(w http.ResponseWriter) bool {
+	if h.imageSource == nil {
+		log.Printf("Nenhuma fonte de imagens configurada")
+		http.Error(w, "Galeria indisponível", http.StatusServiceUnavailable)
+		return false
+	}
+	return true
+}
+
 // GetAltText retorna o texto alternativo para uma imagem específica
 func (h *ImageHandler) GetAltText(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -309,9 +748,7 @@ func (h *ImageHandler) SetAltText(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Limpa o cache para que as próximas requisições reflitam a mudança
-	h.responseMutex.Lock()
-	h.responseCache = nil
-	h.responseMutex.Unlock()
+	h.InvalidateCache()
 
 	w.Header().Set("Content-Type", "application/json")
 	response := map[string]string{"status": "success", "altText": requestBody.AltText}
@@ -0,0 +1,356 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/kollinn/casamento-mari-kollinn/internal/model"
+	"github.com/kollinn/casamento-mari-kollinn/internal/service"
+)
+
+// fakeImageSource é uma fonte de imagens em memória que registra o que foi aberto
+type fakeImageSource struct {
+	images    map[string]*model.DriveImage
+	originals map[string][]byte
+
+	mutex  sync.Mutex
+	opened []string
+}
+
+func newFakeImageSource() *fakeImageSource {
+	return &fakeImageSource{images: make(map[string]*model.DriveImage), originals: make(map[string][]byte)}
+}
+
+// add inclui uma imagem com o original e a versão informados
+func (s *fakeImageSource) add(id string, original []byte, checksum string) {
+	s.images[id] = &model.DriveImage{
+		ID:           id,
+		Name:         id + ".png",
+		MimeType:     "image/png",
+		Checksum:     checksum,
+		ModifiedTime: time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC),
+	}
+	s.originals[id] = original
+}
+
+func (s *fakeImageSource) record(what string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.opened = append(s.opened, what)
+}
+
+// openedList retorna o que foi aberto na fonte, em ordem
+func (s *fakeImageSource) openedList() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]string(nil), s.opened...)
+}
+
+func (s *fakeImageSource) ListImages(ctx context.Context) ([]model.DriveImage, error) {
+	var images []model.DriveImage
+	for _, image := range s.images {
+		images = append(images, *image)
+	}
+	return images, nil
+}
+
+func (s *fakeImageSource) GetImage(ctx context.Context, id string) (*model.DriveImage, error) {
+	image, ok := s.images[id]
+	if !ok {
+		return nil, service.ErrImageNotFound
+	}
+	copied := *image
+	return &copied, nil
+}
+
+func (s *fakeImageSource) OpenImage(ctx context.Context, id string, size string) (io.ReadCloser, string, error) {
+	original, ok := s.originals[id]
+	if !ok {
+		return nil, "", service.ErrImageNotFound
+	}
+	s.record("original:" + id + ":" + size)
+	return io.NopCloser(bytes.NewReader(original)), "image/png", nil
+}
+
+// fakeThumbnailSource acrescenta miniaturas sob medida, como o Drive
+type fakeThumbnailSource struct {
+	*fakeImageSource
+}
+
+func (s fakeThumbnailSource) OpenThumbnail(ctx context.Context, id string, width int) (io.ReadCloser, string, error) {
+	if _, ok := s.images[id]; !ok {
+		return nil, "", service.ErrImageNotFound
+	}
+	s.record(fmt.Sprintf("thumbnail:%s:%d", id, width))
+	return io.NopCloser(bytes.NewReader(encodePNG(width, width/2))), "image/png", nil
+}
+
+// encodePNG gera um PNG com as dimensões informadas
+func encodePNG(width, height int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		img.Set(x, height/2, color.RGBA{200, 100, 50, 255})
+	}
+	var buffer bytes.Buffer
+	png.Encode(&buffer, img)
+	return buffer.Bytes()
+}
+
+// pngHeader gera apenas a assinatura e o IHDR de um PNG: suficiente para
+// image.DecodeConfig, sem alocar a imagem inteira
+func pngHeader(width, height int) []byte {
+	ihdr := make([]byte, 17)
+	copy(ihdr, "IHDR")
+	binary.BigEndian.PutUint32(ihdr[4:], uint32(width))
+	binary.BigEndian.PutUint32(ihdr[8:], uint32(height))
+	ihdr[12], ihdr[13] = 8, 6 // 8 bits, RGBA
+
+	var buffer bytes.Buffer
+	buffer.WriteString("\x89PNG\r\n\x1a\n")
+	binary.Write(&buffer, binary.BigEndian, uint32(13))
+	buffer.Write(ihdr)
+	binary.Write(&buffer, binary.BigEndian, crc32.ChecksumIEEE(ihdr))
+	return buffer.Bytes()
+}
+
+// proxyRequest faz GET /api/images/{id}/proxy com a query e os headers informados
+func proxyRequest(h *ImageHandler, id, query string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/api/images/"+id+"/proxy"+query, nil)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	req = mux.SetURLVars(req, map[string]string{"id": id})
+
+	recorder := httptest.NewRecorder()
+	h.ProxyImage(recorder, req)
+	return recorder
+}
+
+func TestProxyImageServesSmallWidthsFromThumbnails(t *testing.T) {
+	source := newFakeImageSource()
+	source.add("foto", encodePNG(2000, 1000), "v1")
+	h := NewImageHandler(fakeThumbnailSource{source}, nil, nil)
+
+	recorder := proxyRequest(h, "foto", "?w=320", nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", recorder.Code, recorder.Body)
+	}
+
+	opened := source.openedList()
+	if len(opened) != 1 || opened[0] != "thumbnail:foto:320" {
+		t.Errorf("esperava apenas a miniatura de 320 pixels, abriu %v", opened)
+	}
+	config, _, err := image.DecodeConfig(recorder.Body)
+	if err != nil || config.Width != 320 {
+		t.Errorf("variante com largura %d (%v), esperava 320", config.Width, err)
+	}
+}
+
+func TestProxyImageResizesOriginalForLargeWidths(t *testing.T) {
+	source := newFakeImageSource()
+	source.add("foto", encodePNG(2000, 1000), "v1")
+	h := NewImageHandler(fakeThumbnailSource{source}, nil, nil)
+
+	recorder := proxyRequest(h, "foto", "?w=1600", nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", recorder.Code, recorder.Body)
+	}
+	if opened := source.openedList(); len(opened) != 1 || opened[0] != "original:foto:original" {
+		t.Errorf("esperava apenas o original, abriu %v", opened)
+	}
+	config, _, err := image.DecodeConfig(recorder.Body)
+	if err != nil || config.Width != 1600 {
+		t.Errorf("variante com largura %d (%v), esperava 1600", config.Width, err)
+	}
+}
+
+func TestProxyImageFallsBackToThumbnailWhenOriginalIsTooLarge(t *testing.T) {
+	source := newFakeImageSource()
+	source.add("foto", pngHeader(9000, 6000), "v1") // 54 megapixels
+	h := NewImageHandler(fakeThumbnailSource{source}, nil, nil)
+
+	recorder := proxyRequest(h, "foto", "?w=1600", nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", recorder.Code, recorder.Body)
+	}
+	opened := source.openedList()
+	if len(opened) != 2 || opened[1] != "thumbnail:foto:1600" {
+		t.Errorf("esperava o original seguido da miniatura, abriu %v", opened)
+	}
+}
+
+func TestProxyImageRejectsTooLargeOriginalWithoutThumbnails(t *testing.T) {
+	source := newFakeImageSource()
+	source.add("foto", pngHeader(9000, 6000), "v1")
+	h := NewImageHandler(source, nil, nil)
+
+	if recorder := proxyRequest(h, "foto", "?w=1600", nil); recorder.Code != http.StatusUnprocessableEntity {
+		t.Errorf("status %d, esperava %d", recorder.Code, http.StatusUnprocessableEntity)
+	}
+}
+
+func TestProxyImageNegotiatesFormat(t *testing.T) {
+	source := newFakeImageSource()
+	source.add("foto", encodePNG(800, 400), "v1")
+	h := NewImageHandler(source, nil, nil)
+
+	webp := proxyRequest(h, "foto", "?w=320", map[string]string{"Accept": "image/avif,image/webp,*/*"})
+	jpeg := proxyRequest(h, "foto", "?w=320", map[string]string{"Accept": "image/*"})
+
+	if jpeg.Header().Get("Content-Type") != "image/jpeg" {
+		t.Errorf("sem WebP no Accept: Content-Type %q", jpeg.Header().Get("Content-Type"))
+	}
+	wantWebP := service.NegotiateImageFormat("image/webp")
+	if webp.Header().Get("Content-Type") != wantWebP {
+		t.Errorf("com WebP no Accept: Content-Type %q, esperava %q", webp.Header().Get("Content-Type"), wantWebP)
+	}
+	for _, recorder := range []*httptest.ResponseRecorder{webp, jpeg} {
+		if recorder.Header().Get("Vary") != "Accept" {
+			t.Errorf("variante sem Vary: Accept")
+		}
+	}
+	if wantWebP == service.ImageFormatWebP && webp.Header().Get("ETag") == jpeg.Header().Get("ETag") {
+		t.Errorf("formatos diferentes com o mesmo ETag %s", webp.Header().Get("ETag"))
+	}
+}
+
+func TestProxyImageSnapsWidth(t *testing.T) {
+	source := newFakeImageSource()
+	source.add("foto", encodePNG(2000, 1000), "v1")
+	h := NewImageHandler(fakeThumbnailSource{source}, nil, nil)
+
+	recorder := proxyRequest(h, "foto", "?w=333", nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", recorder.Code, recorder.Body)
+	}
+	if opened := source.openedList(); len(opened) != 1 || opened[0] != "thumbnail:foto:480" {
+		t.Errorf("w=333 deveria gerar a variante de 480, abriu %v", opened)
+	}
+
+	for _, query := range []string{"?w=0", "?w=-5", "?w=abc"} {
+		if recorder := proxyRequest(h, "foto", query, nil); recorder.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, esperava %d", query, recorder.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestProxyImageKeepsOriginalsOutOfDiskCache(t *testing.T) {
+	diskCache, err := service.NewDiskImageCache(t.TempDir(), 50<<20)
+	if err != nil {
+		t.Fatalf("NewDiskImageCache: %v", err)
+	}
+	source := newFakeImageSource()
+	source.add("foto", encodePNG(2000, 1000), "v1")
+	h := NewImageHandler(source, nil, diskCache)
+
+	if recorder := proxyRequest(h, "foto", "?w=1600", nil); recorder.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", recorder.Code, recorder.Body)
+	}
+
+	if file, _, ok := diskCache.Get("foto", "original", "v1"); ok {
+		file.Close()
+		t.Error("o original não deveria ser gravado no cache em disco")
+	}
+	file, _, ok := diskCache.Get("foto", service.ImageVariantName(1600, service.ImageFormatJPEG), "v1")
+	if !ok {
+		t.Fatal("a variante gerada deveria estar no cache em disco")
+	}
+	file.Close()
+
+	// A segunda requisição vem do cache, sem abrir o original de novo
+	proxyRequest(h, "foto", "?w=1600", nil)
+	if opened := source.openedList(); len(opened) != 1 {
+		t.Errorf("original aberto %d vezes, esperava 1", len(opened))
+	}
+}
+
+func TestProxyImageETagAndNotModified(t *testing.T) {
+	source := newFakeImageSource()
+	source.add("foto", encodePNG(40, 20), "v1")
+	h := NewImageHandler(source, nil, nil)
+
+	recorder := proxyRequest(h, "foto", "", nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", recorder.Code, recorder.Body)
+	}
+	etag := recorder.Header().Get("ETag")
+	if etag != `"v1-thumbnail"` {
+		t.Errorf("ETag = %s, esperava a versão e a variante", etag)
+	}
+	if recorder.Header().Get("Last-Modified") == "" {
+		t.Error("resposta sem Last-Modified")
+	}
+
+	// O 304 é respondido só com os metadados, sem abrir a imagem
+	for _, ifNoneMatch := range []string{etag, "W/" + etag, `"outra", ` + etag, "*"} {
+		recorder := proxyRequest(h, "foto", "", map[string]string{"If-None-Match": ifNoneMatch})
+		if recorder.Code != http.StatusNotModified || recorder.Body.Len() != 0 {
+			t.Errorf("If-None-Match %s: status %d com %d bytes, esperava 304 vazio", ifNoneMatch, recorder.Code, recorder.Body.Len())
+		}
+	}
+	if opened := source.openedList(); len(opened) != 1 {
+		t.Errorf("imagem aberta %d vezes, esperava 1", len(opened))
+	}
+
+	// Cada largura tem o próprio ETag e varia com o Accept
+	recorder = proxyRequest(h, "foto", "?w=320", map[string]string{"If-None-Match": etag})
+	if recorder.Code != http.StatusOK || recorder.Header().Get("ETag") == etag {
+		t.Errorf("variante com o ETag da miniatura: status %d, ETag %s", recorder.Code, recorder.Header().Get("ETag"))
+	}
+	if recorder.Header().Get("Vary") != "Accept" {
+		t.Errorf("Vary = %q, esperava Accept", recorder.Header().Get("Vary"))
+	}
+
+	// Com a foto substituída na fonte, o ETag antigo não vale mais
+	source.add("foto", encodePNG(40, 20), "v2")
+	recorder = proxyRequest(h, "foto", "", map[string]string{"If-None-Match": etag})
+	if recorder.Code != http.StatusOK || recorder.Header().Get("ETag") != `"v2-thumbnail"` {
+		t.Errorf("versão nova: status %d, ETag %s", recorder.Code, recorder.Header().Get("ETag"))
+	}
+
+	if recorder := proxyRequest(h, "inexistente", "", nil); recorder.Code != http.StatusNotFound {
+		t.Errorf("imagem inexistente: status %d, esperava 404", recorder.Code)
+	}
+}
+
+func TestProxyImageRange(t *testing.T) {
+	original := encodePNG(40, 20)
+	source := newFakeImageSource()
+	source.add("foto", original, "v1")
+	h := NewImageHandler(source, nil, nil)
+
+	recorder := proxyRequest(h, "foto", "", map[string]string{"Range": "bytes=0-9"})
+	if recorder.Code != http.StatusPartialContent {
+		t.Fatalf("status %d, esperava 206", recorder.Code)
+	}
+	if !bytes.Equal(recorder.Body.Bytes(), original[:10]) {
+		t.Errorf("corpo = %v, esperava os 10 primeiros bytes", recorder.Body.Bytes())
+	}
+	if want := fmt.Sprintf("bytes 0-9/%d", len(original)); recorder.Header().Get("Content-Range") != want {
+		t.Errorf("Content-Range = %q, esperava %q", recorder.Header().Get("Content-Range"), want)
+	}
+
+	// If-Range com outra versão devolve a imagem inteira
+	recorder = proxyRequest(h, "foto", "", map[string]string{"Range": "bytes=0-9", "If-Range": `"v0-thumbnail"`})
+	if recorder.Code != http.StatusOK || recorder.Body.Len() != len(original) {
+		t.Errorf("If-Range desatualizado: status %d com %d bytes, esperava 200 com %d", recorder.Code, recorder.Body.Len(), len(original))
+	}
+
+	recorder = proxyRequest(h, "foto", "", map[string]string{"Range": fmt.Sprintf("bytes=%d-", len(original)+10)})
+	if recorder.Code != http.StatusRequestedRangeNotSatisfiable {
+		t.Errorf("intervalo fora da imagem: status %d, esperava 416", recorder.Code)
+	}
+}
@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+
+	"github.com/kollinn/casamento-mari-kollinn/internal/service"
+)
+
+// WebhookHandler recebe as notificações de mudanças enviadas pelo Google Drive
+type WebhookHandler struct {
+	channelToken string
+	refresher    service.BackgroundRefresher
+	imageHandler *ImageHandler
+}
+
+// NewWebhookHandler cria uma nova instância do WebhookHandler.
+// Sem channelToken, todas as notificações são rejeitadas.
+func NewWebhookHandler(channelToken string, refresher service.BackgroundRefresher, imageHandler *ImageHandler) *WebhookHandler {
+	return &WebhookHandler{
+		channelToken: channelToken,
+		refresher:    refresher,
+		imageHandler: imageHandler,
+	}
+}
+
+// DriveNotification trata as notificações do canal changes.watch do Drive
+func (h *WebhookHandler) DriveNotification(w http.ResponseWriter, r *http.Request) {
+	channelID := r.Header.Get("X-Goog-Channel-ID")
+	state := r.Header.Get("X-Goog-Resource-State")
+
+	log.Printf("Recebida notificação do Drive: canal=%s estado=%s", channelID, state)
+
+	token := r.Header.Get("X-Goog-Channel-Token")
+	if h.channelToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(h.channelToken)) != 1 {
+		log.Printf("Token de canal inválido na notificação do Drive")
+		http.Error(w, "Não autorizado", http.StatusUnauthorized)
+		return
+	}
+
+	// A notificação "sync" apenas confirma a criação do canal
+	if state == "sync" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if h.refresher == nil {
+		http.Error(w, "Fonte de imagens não suporta notificações", http.StatusNotImplemented)
+		return
+	}
+
+	// Agenda a sincronização incremental e descarta a resposta em cache;
+	// o Drive só espera a confirmação, então não bloqueamos aqui
+	h.refresher.RequestRefresh()
+	h.imageHandler.InvalidateCache()
+
+	w.WriteHeader(http.StatusOK)
+}
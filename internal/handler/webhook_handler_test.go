@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// countingRefresher registra quantas sincronizações foram pedidas
+type countingRefresher struct {
+	requests int
+}
+
+func (r *countingRefresher) RequestRefresh() {
+	r.requests++
+}
+
+// newTestWebhook cria o handler com uma resposta de GET /api/images em cache
+func newTestWebhook(channelToken string, refresher *countingRefresher) (*WebhookHandler, *ImageHandler) {
+	imageHandler := NewImageHandler(nil, nil, nil)
+	imageHandler.responseCache = &ResponseCache{Data: []byte("[]"), ExpiresAt: time.Now().Add(time.Hour)}
+
+	// Um *countingRefresher nulo não resultaria em uma interface nula
+	if refresher == nil {
+		return NewWebhookHandler(channelToken, nil, imageHandler), imageHandler
+	}
+	return NewWebhookHandler(channelToken, refresher, imageHandler), imageHandler
+}
+
+func driveNotification(token, state string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/api/hooks/drive", nil)
+	req.Header.Set("X-Goog-Channel-ID", "casamento-teste")
+	req.Header.Set("X-Goog-Resource-State", state)
+	if token != "" {
+		req.Header.Set("X-Goog-Channel-Token", token)
+	}
+	return req
+}
+
+func TestDriveNotificationRejectsInvalidToken(t *testing.T) {
+	tests := []struct {
+		name         string
+		channelToken string
+		token        string
+	}{
+		{"sem token na notificação", "segredo", ""},
+		{"token errado", "segredo", "outro"},
+		{"prefixo do token", "segredo", "segr"},
+		{"webhook sem token configurado", "", ""},
+		{"webhook sem token configurado e token enviado", "", "qualquer"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			refresher := &countingRefresher{}
+			webhook, imageHandler := newTestWebhook(tt.channelToken, refresher)
+
+			recorder := httptest.NewRecorder()
+			webhook.DriveNotification(recorder, driveNotification(tt.token, "change"))
+
+			if recorder.Code != http.StatusUnauthorized {
+				t.Errorf("status %d, esperava %d", recorder.Code, http.StatusUnauthorized)
+			}
+			if refresher.requests != 0 {
+				t.Errorf("notificação rejeitada não deveria pedir sincronização")
+			}
+			if imageHandler.responseCache == nil {
+				t.Errorf("notificação rejeitada não deveria descartar o cache")
+			}
+		})
+	}
+}
+
+func TestDriveNotificationSyncOnlyAcknowledges(t *testing.T) {
+	refresher := &countingRefresher{}
+	webhook, imageHandler := newTestWebhook("segredo", refresher)
+
+	recorder := httptest.NewRecorder()
+	webhook.DriveNotification(recorder, driveNotification("segredo", "sync"))
+
+	if recorder.Code != http.StatusOK {
+		t.Errorf("status %d, esperava %d", recorder.Code, http.StatusOK)
+	}
+	if refresher.requests != 0 {
+		t.Errorf("a notificação sync não deveria pedir sincronização")
+	}
+	if imageHandler.responseCache == nil {
+		t.Errorf("a notificação sync não deveria descartar o cache")
+	}
+}
+
+func TestDriveNotificationRequestsRefresh(t *testing.T) {
+	for _, state := range []string{"change", "update", "add", "remove"} {
+		t.Run(state, func(t *testing.T) {
+			refresher := &countingRefresher{}
+			webhook, imageHandler := newTestWebhook("segredo", refresher)
+
+			recorder := httptest.NewRecorder()
+			webhook.DriveNotification(recorder, driveNotification("segredo", state))
+
+			if recorder.Code != http.StatusOK {
+				t.Errorf("status %d, esperava %d", recorder.Code, http.StatusOK)
+			}
+			if refresher.requests != 1 {
+				t.Errorf("sincronizações pedidas: %d, esperava 1", refresher.requests)
+			}
+			if imageHandler.responseCache != nil {
+				t.Errorf("a resposta de GET /api/images deveria ser descartada")
+			}
+		})
+	}
+}
+
+func TestDriveNotificationWithoutRefresher(t *testing.T) {
+	webhook, _ := newTestWebhook("segredo", nil)
+
+	recorder := httptest.NewRecorder()
+	webhook.DriveNotification(recorder, driveNotification("segredo", "change"))
+
+	if recorder.Code != http.StatusNotImplemented {
+		t.Errorf("status %d, esperava %d", recorder.Code, http.StatusNotImplemented)
+	}
+}
@@ -0,0 +1,8 @@
+package model
+
+// Album representa uma subpasta de fotos da galeria (ex: Cerimônia, Festa)
+type Album struct {
+	ID         string
+	Name       string
+	ImageCount int
+}
@@ -1,5 +1,7 @@
 package model
 
+import "time"
+
 // DriveImage representa uma imagem armazenada no Google Drive
 type DriveImage struct {
 	ID            string
@@ -7,5 +9,11 @@ type DriveImage struct {
 	MimeType      string
 	WebViewLink   string
 	ThumbnailLink string
-	AltText       string // Texto alternativo para acessibilidade
+	AltText       string    // Texto alternativo para acessibilidade
+	AlbumID       string    // ID do álbum (subpasta) da imagem; vazio na pasta raiz
+	Album         string    // Nome do álbum, ex: "Cerimônia"
+	Checksum      string    // Hash do conteúdo original (md5Checksum no Drive)
+	ModifiedTime  time.Time // Última modificação do arquivo original
+	Width         int       // Largura do original como exibido, em pixels; 0 se desconhecida
+	Height        int       // Altura do original como exibido, em pixels; 0 se desconhecida
 }
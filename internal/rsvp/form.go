@@ -0,0 +1,194 @@
+package rsvp
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Tipos de pergunta aceitos no questionário
+const (
+	QuestionRadio  = "radio"
+	QuestionSelect = "select"
+	QuestionText   = "text"
+	QuestionNumber = "number"
+)
+
+// defaultTextLength limita as respostas de texto sem maxLength definido
+const defaultTextLength = 300
+
+// defaultFormYAML é o questionário usado quando RSVP_FORM_FILE não é definido
+//
+//go:embed default_form.yaml
+var defaultFormYAML []byte
+
+// questionIDPattern restringe os IDs, que viram chaves das respostas
+var questionIDPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+
+// Form é o questionário feito a cada pessoa que vai comparecer. As mesmas
+// tags servem para o arquivo (YAML ou JSON) e para a resposta da API.
+type Form struct {
+	Questions []Question `json:"questions" yaml:"questions"`
+}
+
+// Question é uma pergunta do questionário. ShowIf lista as respostas de
+// perguntas anteriores que precisam coincidir para a pergunta aparecer.
+type Question struct {
+	ID        string            `json:"id" yaml:"id"`
+	Label     string            `json:"label" yaml:"label"`
+	Type      string            `json:"type" yaml:"type"`
+	Options   []string          `json:"options,omitempty" yaml:"options"`
+	Required  bool              `json:"required,omitempty" yaml:"required"`
+	ShowIf    map[string]string `json:"showIf,omitempty" yaml:"showIf"`
+	Min       *int              `json:"min,omitempty" yaml:"min"`
+	Max       *int              `json:"max,omitempty" yaml:"max"`
+	MaxLength int               `json:"maxLength,omitempty" yaml:"maxLength"`
+}
+
+// LoadForm lê o questionário do arquivo informado, ou o padrão embutido
+// quando file é vazio, e confere se ele é consistente
+func LoadForm(file string) (*Form, error) {
+	data := defaultFormYAML
+	if file != "" {
+		var err error
+		if data, err = os.ReadFile(file); err != nil {
+			return nil, fmt.Errorf("erro ao ler questionário: %v", err)
+		}
+	}
+
+	form := &Form{}
+	if err := yaml.Unmarshal(data, form); err != nil {
+		return nil, fmt.Errorf("erro ao decodificar questionário: %v", err)
+	}
+	if err := form.validate(); err != nil {
+		return nil, fmt.Errorf("questionário inválido: %v", err)
+	}
+	return form, nil
+}
+
+// validate confere tipos, opções e condições das perguntas. As condições só
+// podem depender de perguntas anteriores com opções fixas.
+func (f *Form) validate() error {
+	if len(f.Questions) == 0 {
+		return fmt.Errorf("nenhuma pergunta definida")
+	}
+
+	seen := make(map[string]*Question)
+	for i := range f.Questions {
+		q := &f.Questions[i]
+		if !questionIDPattern.MatchString(q.ID) {
+			return fmt.Errorf("pergunta %d: id %q inválido", i+1, q.ID)
+		}
+		if seen[q.ID] != nil {
+			return fmt.Errorf("pergunta %q repetida", q.ID)
+		}
+		if q.Label == "" {
+			return fmt.Errorf("pergunta %q sem label", q.ID)
+		}
+
+		switch q.Type {
+		case QuestionRadio, QuestionSelect:
+			if len(q.Options) == 0 {
+				return fmt.Errorf("pergunta %q do tipo %s sem opções", q.ID, q.Type)
+			}
+		case QuestionText:
+			if q.MaxLength <= 0 {
+				q.MaxLength = defaultTextLength
+			}
+		case QuestionNumber:
+			if q.Min != nil && q.Max != nil && *q.Min > *q.Max {
+				return fmt.Errorf("pergunta %q com min maior que max", q.ID)
+			}
+		default:
+			return fmt.Errorf("pergunta %q com tipo %q desconhecido (use radio, select, text ou number)", q.ID, q.Type)
+		}
+
+		for id, value := range q.ShowIf {
+			previous := seen[id]
+			if previous == nil {
+				return fmt.Errorf("pergunta %q depende de %q, que não vem antes dela", q.ID, id)
+			}
+			if !contains(previous.Options, value) {
+				return fmt.Errorf("pergunta %q depende de %q = %q, que não é uma opção", q.ID, id, value)
+			}
+		}
+
+		seen[q.ID] = q
+	}
+	return nil
+}
+
+// visible indica se a pergunta aparece para as respostas informadas
+func (q *Question) visible(answers map[string]string) bool {
+	for id, value := range q.ShowIf {
+		if answers[id] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// check confere uma resposta não vazia e retorna a mensagem de erro
+func (q *Question) check(value string) string {
+	switch q.Type {
+	case QuestionRadio, QuestionSelect:
+		if !contains(q.Options, value) {
+			return "escolha uma das opções"
+		}
+	case QuestionText:
+		if utf8.RuneCountInString(value) > q.MaxLength {
+			return fmt.Sprintf("use no máximo %d caracteres", q.MaxLength)
+		}
+	case QuestionNumber:
+		number, err := strconv.Atoi(value)
+		switch {
+		case err != nil:
+			return "informe um número inteiro"
+		case q.Min != nil && number < *q.Min:
+			return fmt.Sprintf("informe um número a partir de %d", *q.Min)
+		case q.Max != nil && number > *q.Max:
+			return fmt.Sprintf("informe um número até %d", *q.Max)
+		}
+	}
+	return ""
+}
+
+// validateAnswers confere as respostas de uma pessoa, na ordem das perguntas.
+// Respostas de perguntas ocultas são descartadas, então uma pergunta que
+// depende de outra oculta também fica oculta. Os problemas são registrados
+// em fields com o prefixo informado.
+func (f *Form) validateAnswers(prefix string, answers map[string]string, fields map[string]string) {
+	known := make(map[string]bool, len(f.Questions))
+	for i := range f.Questions {
+		q := &f.Questions[i]
+		known[q.ID] = true
+
+		if !q.visible(answers) {
+			delete(answers, q.ID)
+			continue
+		}
+
+		value := answers[q.ID]
+		if value == "" {
+			delete(answers, q.ID)
+			if q.Required {
+				fields[prefix+q.ID] = "campo obrigatório"
+			}
+			continue
+		}
+		if message := q.check(value); message != "" {
+			fields[prefix+q.ID] = message
+		}
+	}
+
+	for id := range answers {
+		if !known[id] {
+			fields[prefix+id] = "pergunta desconhecida"
+		}
+	}
+}
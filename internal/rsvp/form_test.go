@@ -0,0 +1,196 @@
+package rsvp
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testHousehold é o convite usado nos testes de validação
+var testHousehold = &Household{Code: "ABCDEFGHJK", Name: "Família Silva", Guests: []string{"Ana Silva", "João Silva"}, MaxCompanions: 1}
+
+func defaultForm(t *testing.T) *Form {
+	t.Helper()
+	form, err := LoadForm("")
+	if err != nil {
+		t.Fatalf("LoadForm: %v", err)
+	}
+	return form
+}
+
+func TestResponseValidate(t *testing.T) {
+	// Respostas completas de quem vai, para as entradas que não são o foco do caso
+	complete := func() map[string]string {
+		return map[string]string{"vegetariano": "Sim", "frutosDoMar": "Sim"}
+	}
+
+	tests := []struct {
+		name        string
+		attendees   []Attendee
+		wantFields  []string          // campos com erro
+		wantAnswers map[string]string // respostas guardadas da primeira pessoa
+	}{
+		{
+			name: "resposta completa",
+			attendees: []Attendee{
+				{Name: "ana silva", Attending: AnswerYes, Answers: map[string]string{"vegetariano": "Não", "frutosDoMar": "Não", "preferencia": "Frango"}},
+				{Name: "João Silva", Attending: AnswerNo},
+			},
+			wantAnswers: map[string]string{"vegetariano": "Não", "frutosDoMar": "Não", "preferencia": "Frango"},
+		},
+		{
+			name: "showIf visível exige a resposta",
+			attendees: []Attendee{
+				{Name: "Ana Silva", Attending: AnswerYes, Answers: map[string]string{"vegetariano": "Não", "frutosDoMar": "Não"}},
+				{Name: "João Silva", Attending: AnswerNo},
+			},
+			wantFields: []string{"attendees[0].answers.preferencia"},
+		},
+		{
+			name: "showIf oculto descarta a resposta",
+			attendees: []Attendee{
+				{Name: "Ana Silva", Attending: AnswerYes, Answers: map[string]string{"vegetariano": "Sim", "frutosDoMar": "Não", "preferencia": "Carne"}},
+				{Name: "João Silva", Attending: AnswerNo},
+			},
+			wantAnswers: map[string]string{"vegetariano": "Sim", "frutosDoMar": "Não"},
+		},
+		{
+			name: "obrigatórias em branco",
+			attendees: []Attendee{
+				{Name: "Ana Silva", Attending: AnswerYes, Answers: map[string]string{"vegetariano": "", "allergies": "amendoim"}},
+				{Name: "João Silva", Attending: AnswerNo},
+			},
+			wantFields: []string{"attendees[0].answers.vegetariano", "attendees[0].answers.frutosDoMar"},
+		},
+		{
+			name: "opção fora da lista",
+			attendees: []Attendee{
+				{Name: "Ana Silva", Attending: AnswerYes, Answers: map[string]string{"vegetariano": "Talvez", "frutosDoMar": "Sim"}},
+				{Name: "João Silva", Attending: AnswerNo},
+			},
+			wantFields: []string{"attendees[0].answers.vegetariano"},
+		},
+		{
+			name: "pergunta desconhecida",
+			attendees: []Attendee{
+				{Name: "Ana Silva", Attending: AnswerYes, Answers: map[string]string{"vegetariano": "Sim", "frutosDoMar": "Sim", "bebida": "Vinho"}},
+				{Name: "João Silva", Attending: AnswerNo},
+			},
+			wantFields: []string{"attendees[0].answers.bebida"},
+		},
+		{
+			name: "quem não vai não responde o questionário",
+			attendees: []Attendee{
+				{Name: "Ana Silva", Attending: AnswerNo, Answers: map[string]string{"bebida": "Vinho"}},
+				{Name: "João Silva", Attending: AnswerYes, Answers: complete()},
+			},
+		},
+		{
+			name: "convidado fora do convite e convidado sem resposta",
+			attendees: []Attendee{
+				{Name: "Pedro Lima", Attending: AnswerYes, Answers: complete()},
+				{Name: "Ana Silva", Attending: AnswerNo},
+			},
+			wantFields: []string{"attendees[0].name", "attendees"},
+		},
+		{
+			name: "convidado repetido",
+			attendees: []Attendee{
+				{Name: "Ana Silva", Attending: AnswerNo},
+				{Name: "ANA SILVA", Attending: AnswerNo},
+				{Name: "João Silva", Attending: AnswerNo},
+			},
+			wantFields: []string{"attendees[1].name"},
+		},
+		{
+			name: "acompanhantes além do limite",
+			attendees: []Attendee{
+				{Name: "Ana Silva", Attending: AnswerNo},
+				{Name: "João Silva", Attending: AnswerNo},
+				{Name: "Pedro", Companion: true, Attending: AnswerYes, Answers: complete()},
+				{Name: "Maria", Companion: true, Attending: AnswerYes, Answers: complete()},
+			},
+			wantFields: []string{"attendees"},
+		},
+		{
+			name: "presença sem Sim ou Não",
+			attendees: []Attendee{
+				{Name: "Ana Silva", Attending: "Talvez"},
+				{Name: "João Silva", Attending: AnswerNo},
+			},
+			wantFields: []string{"attendees[0].attending"},
+		},
+	}
+
+	form := defaultForm(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := &Response{Attendees: tt.attendees}
+			response.Normalize()
+			err := response.Validate(testHousehold, form)
+
+			var got []string
+			if err != nil {
+				validationErr, ok := err.(*ValidationError)
+				if !ok {
+					t.Fatalf("esperava *ValidationError, obteve %T: %v", err, err)
+				}
+				for field := range validationErr.Fields {
+					got = append(got, field)
+				}
+			}
+			if !sameFields(got, tt.wantFields) {
+				t.Errorf("campos com erro = %v, esperava %v", got, tt.wantFields)
+			}
+			if tt.wantAnswers != nil && !reflect.DeepEqual(response.Attendees[0].Answers, tt.wantAnswers) {
+				t.Errorf("respostas guardadas = %v, esperava %v", response.Attendees[0].Answers, tt.wantAnswers)
+			}
+		})
+	}
+}
+
+func TestLoadFormRejectsInconsistentForms(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want string
+	}{
+		{"sem perguntas", "questions: []", "nenhuma pergunta"},
+		{"tipo desconhecido", "questions:\n  - {id: a, label: A, type: checkbox}", "tipo"},
+		{"radio sem opções", "questions:\n  - {id: a, label: A, type: radio}", "sem opções"},
+		{"showIf de pergunta posterior", "questions:\n  - {id: a, label: A, type: text, showIf: {b: Sim}}\n  - {id: b, label: B, type: radio, options: [Sim]}", "não vem antes"},
+		{"showIf com valor fora das opções", "questions:\n  - {id: a, label: A, type: radio, options: [Sim, Não]}\n  - {id: b, label: B, type: text, showIf: {a: Talvez}}", "não é uma opção"},
+		{"id repetido", "questions:\n  - {id: a, label: A, type: text}\n  - {id: a, label: B, type: text}", "repetida"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "form.yaml")
+			if err := os.WriteFile(file, []byte(tt.yaml), 0644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := LoadForm(file)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("LoadForm = %v, esperava erro com %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// sameFields compara as listas de campos sem considerar a ordem
+func sameFields(got, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	seen := make(map[string]bool, len(got))
+	for _, field := range got {
+		seen[field] = true
+	}
+	for _, field := range want {
+		if !seen[field] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,293 @@
+package rsvp
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kollinn/casamento-mari-kollinn/internal/fileutil"
+)
+
+// Caracteres dos códigos de convite, sem os que se confundem (0/O, 1/I/L)
+const inviteCodeAlphabet = "23456789ABCDEFGHJKMNPQRSTUVWXYZ"
+
+// inviteCodeLength é o tamanho dos códigos gerados: com 31 caracteres, 10
+// dão cerca de 8×10^14 combinações, fora do alcance de quem tenta adivinhar
+// dentro dos limites por IP do Handler. Códigos mais curtos já enviados
+// continuam valendo.
+const inviteCodeLength = 10
+
+// ErrInvalidCode indica um código de convite desconhecido
+var ErrInvalidCode = errors.New("código de convite inválido")
+
+// Household é uma família ou grupo convidado, identificado pelo código do convite
+type Household struct {
+	Code          string   `json:"code"`
+	Name          string   `json:"name"`
+	Guests        []string `json:"guests"`
+	MaxCompanions int      `json:"maxCompanions"`
+	Contact       string   `json:"contact,omitempty"`
+}
+
+// GuestStore guarda a lista de convidados em um arquivo JSON. O arquivo é
+// relido quando muda, então uma importação feita com o servidor no ar passa
+// a valer sem reiniciá-lo.
+type GuestStore struct {
+	dataFile   string
+	households map[string]*Household
+	modTime    time.Time
+	mutex      sync.RWMutex
+}
+
+// NewGuestStore carrega a lista de convidados do arquivo informado
+func NewGuestStore(dataFile string) (*GuestStore, error) {
+	store := &GuestStore{
+		dataFile:   dataFile,
+		households: make(map[string]*Household),
+	}
+	if err := store.load(); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Carregados %d convites", len(store.households))
+	return store, nil
+}
+
+// NormalizeCode padroniza um código digitado pelo convidado
+func NormalizeCode(code string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(code), ""))
+}
+
+// Lookup retorna uma cópia do convite com o código informado
+func (gs *GuestStore) Lookup(code string) (*Household, error) {
+	gs.reloadIfChanged()
+
+	gs.mutex.RLock()
+	defer gs.mutex.RUnlock()
+
+	household, ok := gs.households[NormalizeCode(code)]
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+	copied := *household
+	copied.Guests = append([]string(nil), household.Guests...)
+	return &copied, nil
+}
+
+// List retorna todos os convites, ordenados pelo nome
+func (gs *GuestStore) List() []Household {
+	gs.reloadIfChanged()
+
+	gs.mutex.RLock()
+	defer gs.mutex.RUnlock()
+
+	households := make([]Household, 0, len(gs.households))
+	for _, household := range gs.households {
+		households = append(households, *household)
+	}
+	sort.Slice(households, func(i, j int) bool {
+		return households[i].Name < households[j].Name
+	})
+	return households
+}
+
+// Import adiciona ou atualiza convites. Um convite existente é reconhecido
+// pelo código ou, sem código, pelo nome, e mantém o código já enviado.
+// Com replace, os convites que não estão na lista são removidos.
+// Retorna os convites gravados, já com os códigos.
+func (gs *GuestStore) Import(households []Household, replace bool) ([]Household, error) {
+	gs.mutex.Lock()
+	defer gs.mutex.Unlock()
+
+	if err := gs.loadLocked(); err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]*Household, len(gs.households))
+	for _, household := range gs.households {
+		byName[strings.ToLower(household.Name)] = household
+	}
+
+	updated := make(map[string]*Household)
+	if !replace {
+		for code, household := range gs.households {
+			updated[code] = household
+		}
+	}
+
+	imported := make([]Household, 0, len(households))
+	seen := make(map[string]string)
+	for i, household := range households {
+		household.Code = NormalizeCode(household.Code)
+		if err := household.validate(); err != nil {
+			return nil, fmt.Errorf("convite %d (%s): %v", i+1, household.Name, err)
+		}
+
+		if household.Code == "" {
+			if existing, ok := byName[strings.ToLower(household.Name)]; ok {
+				household.Code = existing.Code
+			}
+		}
+		if household.Code == "" {
+			code, err := gs.newCode(updated)
+			if err != nil {
+				return nil, err
+			}
+			household.Code = code
+		}
+
+		if other, ok := seen[household.Code]; ok {
+			return nil, fmt.Errorf("código %s repetido (%s e %s)", household.Code, other, household.Name)
+		}
+		seen[household.Code] = household.Name
+
+		stored := household
+		updated[household.Code] = &stored
+		imported = append(imported, household)
+	}
+
+	previous := gs.households
+	gs.households = updated
+	if err := gs.saveLocked(); err != nil {
+		gs.households = previous
+		return nil, err
+	}
+	return imported, nil
+}
+
+// validate confere os campos de um convite
+func (h *Household) validate() error {
+	h.Name = strings.TrimSpace(h.Name)
+	if h.Name == "" {
+		return errors.New("nome da família obrigatório")
+	}
+
+	guests := make([]string, 0, len(h.Guests))
+	for _, guest := range h.Guests {
+		if guest = strings.Join(strings.Fields(guest), " "); guest != "" {
+			guests = append(guests, guest)
+		}
+	}
+	h.Guests = guests
+	if len(h.Guests) == 0 {
+		return errors.New("informe ao menos um convidado")
+	}
+
+	if h.MaxCompanions < 0 {
+		return errors.New("número de acompanhantes não pode ser negativo")
+	}
+	for _, c := range h.Code {
+		if !strings.ContainsRune(inviteCodeAlphabet, c) {
+			return fmt.Errorf("código %q contém caracteres não permitidos", h.Code)
+		}
+	}
+	return nil
+}
+
+// newCode gera um código que ainda não está em uso
+func (gs *GuestStore) newCode(used map[string]*Household) (string, error) {
+	for attempt := 0; attempt < 100; attempt++ {
+		var b strings.Builder
+		for i := 0; i < inviteCodeLength; i++ {
+			n, err := rand.Int(rand.Reader, big.NewInt(int64(len(inviteCodeAlphabet))))
+			if err != nil {
+				return "", err
+			}
+			b.WriteByte(inviteCodeAlphabet[n.Int64()])
+		}
+		if _, exists := used[b.String()]; !exists {
+			return b.String(), nil
+		}
+	}
+	return "", errors.New("não foi possível gerar um código de convite único")
+}
+
+// reloadIfChanged relê o arquivo se ele foi alterado por outro processo
+func (gs *GuestStore) reloadIfChanged() {
+	info, err := os.Stat(gs.dataFile)
+	if err != nil {
+		return
+	}
+
+	gs.mutex.RLock()
+	changed := !info.ModTime().Equal(gs.modTime)
+	gs.mutex.RUnlock()
+	if !changed {
+		return
+	}
+
+	if err := gs.load(); err != nil {
+		log.Printf("Aviso: mantendo a lista de convidados anterior: %v", err)
+		return
+	}
+	log.Printf("Lista de convidados recarregada: %d convites", len(gs.households))
+}
+
+func (gs *GuestStore) load() error {
+	gs.mutex.Lock()
+	defer gs.mutex.Unlock()
+	return gs.loadLocked()
+}
+
+// loadLocked lê o arquivo. Deve ser chamado com o mutex travado.
+func (gs *GuestStore) loadLocked() error {
+	info, err := os.Stat(gs.dataFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("erro ao ler lista de convidados: %v", err)
+	}
+
+	data, err := os.ReadFile(gs.dataFile)
+	if err != nil {
+		return fmt.Errorf("erro ao ler lista de convidados: %v", err)
+	}
+
+	var households []*Household
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &households); err != nil {
+			return fmt.Errorf("erro ao decodificar lista de convidados: %v", err)
+		}
+	}
+
+	loaded := make(map[string]*Household, len(households))
+	for _, household := range households {
+		loaded[NormalizeCode(household.Code)] = household
+	}
+	gs.households = loaded
+	gs.modTime = info.ModTime()
+	return nil
+}
+
+// saveLocked grava a lista de convidados. Deve ser chamado com o mutex travado.
+func (gs *GuestStore) saveLocked() error {
+	households := make([]*Household, 0, len(gs.households))
+	for _, household := range gs.households {
+		households = append(households, household)
+	}
+	sort.Slice(households, func(i, j int) bool {
+		return households[i].Name < households[j].Name
+	})
+
+	data, err := json.MarshalIndent(households, "", "  ")
+	if err != nil {
+		return fmt.Errorf("erro ao codificar lista de convidados: %v", err)
+	}
+	if err := fileutil.WriteFileAtomic(gs.dataFile, data, 0600); err != nil {
+		return fmt.Errorf("erro ao salvar lista de convidados: %v", err)
+	}
+
+	if info, err := os.Stat(gs.dataFile); err == nil {
+		gs.modTime = info.ModTime()
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package rsvp
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestGuestStoreImport(t *testing.T) {
+	initial := []Household{
+		{Name: "Família Silva", Guests: []string{"Ana Silva", "João Silva"}, MaxCompanions: 1},
+		{Name: "Tia Rosa", Guests: []string{"Rosa"}},
+		{Code: "casa 2345", Name: "Padrinhos", Guests: []string{"Carlos"}},
+	}
+
+	tests := []struct {
+		name      string
+		imported  []Household
+		replace   bool
+		wantErr   bool
+		wantNames []string // convites na lista depois da importação
+		keepCodes []string // convites que mantêm o código da primeira importação
+	}{
+		{
+			name: "reimportar mantém os códigos pelo nome",
+			imported: []Household{
+				{Name: "família silva", Guests: []string{"Ana Silva", "João Silva", "Bia Silva"}},
+				{Name: "Tia Rosa", Guests: []string{"Rosa"}, MaxCompanions: 1},
+			},
+			wantNames: []string{"família silva", "Padrinhos", "Tia Rosa"},
+			keepCodes: []string{"família silva", "Tia Rosa", "Padrinhos"},
+		},
+		{
+			name: "código informado prevalece sobre o nome",
+			imported: []Household{
+				{Code: "CASA2345", Name: "Padrinhos Souza", Guests: []string{"Carlos", "Marta"}},
+			},
+			wantNames: []string{"Família Silva", "Padrinhos Souza", "Tia Rosa"},
+			keepCodes: []string{"Padrinhos Souza"},
+		},
+		{
+			name: "replace remove os convites ausentes",
+			imported: []Household{
+				{Name: "Tia Rosa", Guests: []string{"Rosa"}},
+				{Name: "Primos", Guests: []string{"Lucas", "Léo"}},
+			},
+			replace:   true,
+			wantNames: []string{"Primos", "Tia Rosa"},
+			keepCodes: []string{"Tia Rosa"},
+		},
+		{
+			name:     "código repetido",
+			imported: []Household{{Code: "AAAA", Name: "Um", Guests: []string{"A"}}, {Code: "aaaa", Name: "Dois", Guests: []string{"B"}}},
+			wantErr:  true,
+		},
+		{
+			name:     "código com caracteres ambíguos",
+			imported: []Household{{Code: "OI10", Name: "Um", Guests: []string{"A"}}},
+			wantErr:  true,
+		},
+		{
+			name:     "convite sem convidados",
+			imported: []Household{{Name: "Vazio", Guests: []string{"  "}}},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dataFile := filepath.Join(t.TempDir(), "guests.json")
+			store, err := NewGuestStore(dataFile)
+			if err != nil {
+				t.Fatalf("NewGuestStore: %v", err)
+			}
+			first, err := store.Import(initial, false)
+			if err != nil {
+				t.Fatalf("primeira importação: %v", err)
+			}
+			codes := make(map[string]string)
+			for _, household := range first {
+				codes[household.Name] = household.Code
+			}
+			// Nomes com que os convites são reimportados
+			codes["família silva"] = codes["Família Silva"]
+			codes["Padrinhos Souza"] = codes["Padrinhos"]
+
+			_, err = store.Import(tt.imported, tt.replace)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("esperava erro na importação")
+				}
+				if got := len(store.List()); got != len(initial) {
+					t.Errorf("importação recusada alterou a lista: %d convites", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Import: %v", err)
+			}
+
+			// A lista relida do arquivo é a mesma da memória
+			reloaded, err := NewGuestStore(dataFile)
+			if err != nil {
+				t.Fatalf("NewGuestStore ao reler: %v", err)
+			}
+			list := reloaded.List()
+			var names []string
+			byName := make(map[string]Household)
+			for _, household := range list {
+				names = append(names, household.Name)
+				byName[household.Name] = household
+			}
+			if !sameFields(names, tt.wantNames) {
+				t.Errorf("convites = %v, esperava %v", names, tt.wantNames)
+			}
+			for _, name := range tt.keepCodes {
+				if byName[name].Code != codes[name] {
+					t.Errorf("%s: código %q, esperava o já enviado %q", name, byName[name].Code, codes[name])
+				}
+			}
+			for _, household := range list {
+				if _, err := reloaded.Lookup(household.Code); err != nil {
+					t.Errorf("Lookup(%s): %v", household.Code, err)
+				}
+			}
+		})
+	}
+}
+
+func TestGuestStoreLookupNormalizesCode(t *testing.T) {
+	store, err := NewGuestStore(filepath.Join(t.TempDir(), "guests.json"))
+	if err != nil {
+		t.Fatalf("NewGuestStore: %v", err)
+	}
+	if _, err := store.Import([]Household{{Code: "ABCD2345", Name: "Família", Guests: []string{"Ana"}}}, false); err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+
+	if household, err := store.Lookup(" abcd 2345 "); err != nil || household.Name != "Família" {
+		t.Errorf("Lookup com espaços e minúsculas = %+v, %v", household, err)
+	}
+	if _, err := store.Lookup("ABCD2346"); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("código desconhecido: esperava ErrInvalidCode, obteve %v", err)
+	}
+}
@@ -0,0 +1,338 @@
+package rsvp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// maxRequestBytes limita o tamanho do corpo de uma resposta
+const maxRequestBytes = 64 << 10
+
+// Handler expõe as confirmações de presença na API
+type Handler struct {
+	store    *Store
+	guests   *GuestStore
+	form     *Form
+	deadline time.Time
+
+	// Limites por IP das consultas de código de convite
+	lookups    *attemptLimiter
+	failures   *attemptLimiter
+	trustProxy bool
+}
+
+// NewHandler cria uma nova instância do Handler. Com deadline diferente de
+// zero, as respostas não podem ser enviadas nem alteradas depois dele. Com
+// trustProxy, o IP usado nos limites de tentativas vem do X-Forwarded-For.
+func NewHandler(store *Store, guests *GuestStore, form *Form, deadline time.Time, trustProxy bool) *Handler {
+	return &Handler{
+		store:      store,
+		guests:     guests,
+		form:       form,
+		deadline:   deadline,
+		lookups:    newAttemptLimiter(lookupLimit, lookupWindow),
+		failures:   newAttemptLimiter(failureLimit, failureWindow),
+		trustProxy: trustProxy,
+	}
+}
+
+// errorResponse é o corpo das respostas de erro, com os campos inválidos.
+// Closed indica que o prazo de confirmação terminou.
+type errorResponse struct {
+	Error  string            `json:"error"`
+	Fields map[string]string `json:"fields,omitempty"`
+	Closed bool              `json:"closed,omitempty"`
+}
+
+// invitationResponse é o convite com a resposta já enviada, se houver, e o
+// prazo para respondê-lo
+type invitationResponse struct {
+	*Household
+	Response *Response  `json:"response,omitempty"`
+	Deadline *time.Time `json:"deadline,omitempty"`
+	Closed   bool       `json:"closed"`
+}
+
+// Create recebe uma confirmação de presença (POST /api/rsvp)
+func (h *Handler) Create(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Recebida requisição POST /api/rsvp")
+
+	if h.rejectClosed(w) {
+		return
+	}
+	ip, ok := h.throttle(w, r)
+	if !ok {
+		return
+	}
+
+	var response Response
+	if !decodeResponse(w, r, &response) {
+		return
+	}
+
+	// Só aceita respostas de quem recebeu um convite
+	household, err := h.lookupCode(ip, response.Code)
+	if err != nil {
+		log.Printf("Confirmação de presença com código de convite inválido")
+		writeJSON(w, http.StatusForbidden, errorResponse{
+			Error:  "Código de convite inválido. Use o link recebido no convite.",
+			Fields: map[string]string{"code": "código não encontrado"},
+		})
+		return
+	}
+
+	err = h.store.Create(&response, household, h.form)
+	if errors.Is(err, ErrAlreadyAnswered) {
+		log.Printf("Convite %s já respondido", household.Code)
+		writeJSON(w, http.StatusConflict, errorResponse{Error: "Este convite já foi respondido. Altere a resposta pelo link do convite."})
+		return
+	}
+	if err != nil {
+		writeSaveError(w, err)
+		return
+	}
+
+	log.Printf("Confirmação de presença %s registrada", response.ID)
+	writeJSON(w, http.StatusCreated, publicResponse(&response))
+}
+
+// Update altera a resposta de um convite (PUT /api/rsvp/invitation/{code})
+func (h *Handler) Update(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Recebida requisição PUT /api/rsvp/invitation")
+
+	if h.rejectClosed(w) {
+		return
+	}
+	household, ok := h.lookupHousehold(w, r)
+	if !ok {
+		return
+	}
+
+	var response Response
+	if !decodeResponse(w, r, &response) {
+		return
+	}
+
+	err := h.store.Update(&response, household, h.form)
+	if errors.Is(err, ErrNotFound) {
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: "Este convite ainda não foi respondido"})
+		return
+	}
+	if err != nil {
+		writeSaveError(w, err)
+		return
+	}
+
+	log.Printf("Confirmação de presença %s alterada", response.ID)
+	writeJSON(w, http.StatusOK, publicResponse(&response))
+}
+
+// Withdraw cancela a resposta de um convite: ninguém do convite vai
+// comparecer (DELETE /api/rsvp/invitation/{code})
+func (h *Handler) Withdraw(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Recebida requisição DELETE /api/rsvp/invitation")
+
+	if h.rejectClosed(w) {
+		return
+	}
+	household, ok := h.lookupHousehold(w, r)
+	if !ok {
+		return
+	}
+
+	response, err := h.store.Withdraw(household)
+	if errors.Is(err, ErrNotFound) {
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: "Este convite ainda não foi respondido"})
+		return
+	}
+	if err != nil {
+		log.Printf("Erro ao cancelar confirmação de presença: %v", err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: "Erro ao cancelar a confirmação, tente novamente"})
+		return
+	}
+
+	log.Printf("Confirmação de presença %s cancelada", response.ID)
+	writeJSON(w, http.StatusOK, publicResponse(response))
+}
+
+// Get retorna uma confirmação de presença pelo ID (GET /api/rsvp/{id})
+func (h *Handler) Get(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	response, err := h.store.Get(id)
+	if errors.Is(err, ErrNotFound) {
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: "Confirmação não encontrada"})
+		return
+	}
+	if err != nil {
+		log.Printf("Erro ao buscar confirmação de presença %s: %v", id, err)
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: "Erro ao buscar a confirmação"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, publicResponse(response))
+}
+
+// Invitation retorna os dados de um convite para preencher o formulário,
+// com a resposta já enviada e o prazo (GET /api/rsvp/invitation/{code})
+func (h *Handler) Invitation(w http.ResponseWriter, r *http.Request) {
+	household, ok := h.lookupHousehold(w, r)
+	if !ok {
+		return
+	}
+
+	// O contato fica restrito aos noivos
+	household.Contact = ""
+	invitation := invitationResponse{Household: household, Closed: h.closed()}
+	if !h.deadline.IsZero() {
+		invitation.Deadline = &h.deadline
+	}
+	if response, err := h.store.FindByCode(household.Code); err == nil {
+		invitation.Response = publicResponse(response)
+	}
+	writeJSON(w, http.StatusOK, invitation)
+}
+
+// Form retorna o questionário feito a cada pessoa que vai comparecer
+// (GET /api/rsvp/form)
+func (h *Handler) Form(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.form)
+}
+
+// Changes lista as alterações das respostas, da mais antiga para a mais
+// recente, opcionalmente a partir de uma data
+// (GET /api/admin/rsvp/changes?since=2026-11-23)
+func (h *Handler) Changes(w http.ResponseWriter, r *http.Request) {
+	var since time.Time
+	if value := r.URL.Query().Get("since"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			parsed, err = time.Parse("2006-01-02", value)
+		}
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: "since inválido: use uma data como 2026-11-23"})
+			return
+		}
+		since = parsed
+	}
+
+	changes := h.store.Changes(since)
+	if changes == nil {
+		changes = []HouseholdChange{}
+	}
+	writeJSON(w, http.StatusOK, changes)
+}
+
+// closed indica se o prazo de confirmação terminou
+func (h *Handler) closed() bool {
+	return !h.deadline.IsZero() && time.Now().After(h.deadline)
+}
+
+// rejectClosed responde 403 com closed: true se o prazo terminou
+func (h *Handler) rejectClosed(w http.ResponseWriter) bool {
+	if !h.closed() {
+		return false
+	}
+	log.Printf("Confirmação de presença recusada: prazo encerrado")
+	writeJSON(w, http.StatusForbidden, errorResponse{
+		Error:  fmt.Sprintf("O prazo para confirmar presença terminou em %s.", h.deadline.Format("02/01/2006 às 15:04")),
+		Closed: true,
+	})
+	return true
+}
+
+// lookupHousehold busca o convite do código na URL, respondendo 404 se ele
+// não existir e 429 se o IP esgotou as tentativas
+func (h *Handler) lookupHousehold(w http.ResponseWriter, r *http.Request) (*Household, bool) {
+	ip, ok := h.throttle(w, r)
+	if !ok {
+		return nil, false
+	}
+	household, err := h.lookupCode(ip, mux.Vars(r)["code"])
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: "Convite não encontrado"})
+		return nil, false
+	}
+	return household, true
+}
+
+// throttle aplica os limites por IP antes de consultar um código de convite,
+// respondendo 429 com Retry-After quando eles foram esgotados. Retorna o IP
+// do cliente.
+func (h *Handler) throttle(w http.ResponseWriter, r *http.Request) (string, bool) {
+	ip := clientIP(r, h.trustProxy)
+
+	wait, blocked := h.failures.blocked(ip)
+	if !blocked {
+		wait, blocked = h.lookups.blocked(ip)
+	}
+	if blocked {
+		log.Printf("Consulta de convite bloqueada para %s por %s", ip, wait.Round(time.Second))
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		writeJSON(w, http.StatusTooManyRequests, errorResponse{Error: "Muitas tentativas. Aguarde alguns minutos e tente de novo."})
+		return ip, false
+	}
+
+	h.lookups.add(ip)
+	return ip, true
+}
+
+// lookupCode busca o convite do código, contando os códigos inválidos do IP
+func (h *Handler) lookupCode(ip, code string) (*Household, error) {
+	household, err := h.guests.Lookup(code)
+	if err != nil {
+		h.failures.add(ip)
+	}
+	return household, err
+}
+
+// decodeResponse lê a resposta do corpo da requisição, respondendo 400 se
+// ele for inválido
+func decodeResponse(w http.ResponseWriter, r *http.Request, response *Response) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBytes)
+	if err := json.NewDecoder(r.Body).Decode(response); err != nil {
+		log.Printf("Erro ao decodificar corpo da requisição: %v", err)
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "Corpo da requisição inválido"})
+		return false
+	}
+	return true
+}
+
+// writeSaveError responde a uma falha ao gravar uma resposta: 400 com os
+// campos inválidos ou 500
+func writeSaveError(w http.ResponseWriter, err error) {
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		log.Printf("Confirmação de presença recusada: %v", err)
+		writeJSON(w, http.StatusBadRequest, errorResponse{
+			Error:  "Confira os campos destacados",
+			Fields: validationErr.Fields,
+		})
+		return
+	}
+	log.Printf("Erro ao salvar confirmação de presença: %v", err)
+	writeJSON(w, http.StatusInternalServerError, errorResponse{Error: "Erro ao salvar a confirmação, tente novamente"})
+}
+
+// publicResponse remove o histórico, que fica restrito aos noivos
+func publicResponse(response *Response) *Response {
+	response.History = nil
+	return response
+}
+
+// writeJSON escreve o corpo em JSON com o status informado
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("Erro ao codificar resposta JSON: %v", err)
+	}
+}
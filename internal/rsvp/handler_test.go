@@ -0,0 +1,118 @@
+package rsvp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// newTestHandler cria um Handler com um convite ("Família Silva") gravado em
+// um diretório temporário e retorna o código dele
+func newTestHandler(t *testing.T, trustProxy bool) (*Handler, string) {
+	t.Helper()
+	dir := t.TempDir()
+
+	store, err := NewStore(filepath.Join(dir, "rsvp.json"))
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	guests, err := NewGuestStore(filepath.Join(dir, "guests.json"))
+	if err != nil {
+		t.Fatalf("NewGuestStore: %v", err)
+	}
+	imported, err := guests.Import([]Household{{Name: "Família Silva", Guests: []string{"Ana Silva"}}}, false)
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	form, err := LoadForm("")
+	if err != nil {
+		t.Fatalf("LoadForm: %v", err)
+	}
+	return NewHandler(store, guests, form, time.Time{}, trustProxy), imported[0].Code
+}
+
+// getInvitation faz GET /api/rsvp/invitation/{code} a partir do endereço informado
+func getInvitation(h *Handler, code, remoteAddr, forwardedFor string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/api/rsvp/invitation/"+code, nil)
+	req.RemoteAddr = remoteAddr
+	if forwardedFor != "" {
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+	}
+	req = mux.SetURLVars(req, map[string]string{"code": code})
+	rec := httptest.NewRecorder()
+	h.Invitation(rec, req)
+	return rec
+}
+
+func TestGeneratedInviteCodeLength(t *testing.T) {
+	_, code := newTestHandler(t, false)
+	if len(code) != inviteCodeLength || inviteCodeLength < 10 {
+		t.Errorf("código gerado %q com %d caracteres, esperava %d (mínimo 10)", code, len(code), inviteCodeLength)
+	}
+}
+
+func TestInvitationBlocksIPAfterInvalidCodes(t *testing.T) {
+	h, code := newTestHandler(t, false)
+
+	for i := 0; i < failureLimit; i++ {
+		if rec := getInvitation(h, "CHUTE"+string(rune('A'+i)), "203.0.113.7:5000", ""); rec.Code != http.StatusNotFound {
+			t.Fatalf("tentativa %d: status %d, esperava 404", i+1, rec.Code)
+		}
+	}
+
+	// Bloqueado, nem o código certo é consultado
+	rec := getInvitation(h, code, "203.0.113.7:5001", "")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("depois de %d códigos inválidos: status %d, esperava 429", failureLimit, rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("resposta 429 sem Retry-After")
+	}
+
+	if rec := getInvitation(h, code, "198.51.100.2:5000", ""); rec.Code != http.StatusOK {
+		t.Errorf("outro IP: status %d, esperava 200", rec.Code)
+	}
+}
+
+func TestInvitationLimitsLookupRate(t *testing.T) {
+	h, code := newTestHandler(t, false)
+
+	for i := 0; i < lookupLimit; i++ {
+		if rec := getInvitation(h, code, "203.0.113.7:5000", ""); rec.Code != http.StatusOK {
+			t.Fatalf("consulta %d: status %d, esperava 200", i+1, rec.Code)
+		}
+	}
+	if rec := getInvitation(h, code, "203.0.113.7:5000", ""); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("consulta além do limite: status %d, esperava 429", rec.Code)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name         string
+		trustProxy   bool
+		forwardedFor string
+		want         string
+	}{
+		{"sem proxy ignora o cabeçalho", false, "198.51.100.9", "10.0.0.1"},
+		{"atrás do proxy", true, "198.51.100.9", "198.51.100.9"},
+		{"entrada forjada pelo cliente", true, "1.2.3.4, 198.51.100.9", "198.51.100.9"},
+		{"proxy sem cabeçalho", true, "", "10.0.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = "10.0.0.1:44321"
+			if tt.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			}
+			if got := clientIP(req, tt.trustProxy); got != tt.want {
+				t.Errorf("clientIP = %q, esperava %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package rsvp
+
+import (
+	"time"
+)
+
+// Ações registradas no histórico de uma resposta
+const (
+	ActionCreated   = "created"
+	ActionUpdated   = "updated"
+	ActionWithdrawn = "withdrawn"
+)
+
+// Change é uma alteração de uma resposta, com as mudanças de presença de
+// cada pessoa. Alterações só de cardápio ficam com Attendance vazio.
+type Change struct {
+	At         time.Time          `json:"at"`
+	Action     string             `json:"action"`
+	Attendance []AttendanceChange `json:"attendance,omitempty"`
+}
+
+// AttendanceChange é a mudança de presença de uma pessoa. From vazio indica
+// uma pessoa incluída na resposta; To vazio, uma pessoa removida dela.
+type AttendanceChange struct {
+	Name string `json:"name"`
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+}
+
+// HouseholdChange é uma alteração acompanhada do convite a que pertence
+type HouseholdChange struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+	Change
+}
+
+// diffAttendance compara a presença das pessoas antes e depois de uma
+// alteração, reconhecendo cada pessoa pela vaga que ocupa no convite: a
+// posição entre os convidados nomeados ou entre os acompanhantes. Assim, um
+// acompanhante que teve o nome corrigido continua sendo a mesma pessoa.
+func diffAttendance(before, after []Attendee) []AttendanceChange {
+	previous := make(map[attendeeSlot]Attendee, len(before))
+	for i, slot := range attendeeSlots(before) {
+		previous[slot] = before[i]
+	}
+
+	var changes []AttendanceChange
+	for i, slot := range attendeeSlots(after) {
+		attendee := after[i]
+		old, existed := previous[slot]
+		delete(previous, slot)
+		if existed && old.Attending == attendee.Attending {
+			continue
+		}
+		changes = append(changes, AttendanceChange{Name: attendee.Name, From: old.Attending, To: attendee.Attending})
+	}
+
+	// Pessoas que saíram da resposta, na ordem em que estavam
+	for i, slot := range attendeeSlots(before) {
+		if _, removed := previous[slot]; removed {
+			changes = append(changes, AttendanceChange{Name: before[i].Name, From: before[i].Attending})
+		}
+	}
+	return changes
+}
+
+// attendeeSlot é a vaga de uma pessoa no convite
+type attendeeSlot struct {
+	companion bool
+	index     int
+}
+
+// attendeeSlots numera as pessoas da resposta separadamente entre
+// convidados nomeados e acompanhantes
+func attendeeSlots(attendees []Attendee) []attendeeSlot {
+	slots := make([]attendeeSlot, len(attendees))
+	guests, companions := 0, 0
+	for i, attendee := range attendees {
+		if attendee.Companion {
+			slots[i] = attendeeSlot{companion: true, index: companions}
+			companions++
+		} else {
+			slots[i] = attendeeSlot{index: guests}
+			guests++
+		}
+	}
+	return slots
+}
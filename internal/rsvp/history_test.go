@@ -0,0 +1,69 @@
+package rsvp
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffAttendance(t *testing.T) {
+	guest := func(name, attending string) Attendee {
+		return Attendee{Name: name, Attending: attending}
+	}
+	companion := func(name, attending string) Attendee {
+		return Attendee{Name: name, Companion: true, Attending: attending}
+	}
+
+	tests := []struct {
+		name          string
+		before, after []Attendee
+		want          []AttendanceChange
+	}{
+		{
+			name:  "resposta nova",
+			after: []Attendee{guest("Ana", AnswerYes), companion("Pedro", AnswerYes)},
+			want:  []AttendanceChange{{Name: "Ana", To: AnswerYes}, {Name: "Pedro", To: AnswerYes}},
+		},
+		{
+			name:   "sem mudança de presença",
+			before: []Attendee{guest("Ana", AnswerYes), guest("João", AnswerNo)},
+			after:  []Attendee{guest("Ana", AnswerYes), guest("João", AnswerNo)},
+		},
+		{
+			name:   "convidado muda de ideia",
+			before: []Attendee{guest("Ana", AnswerYes), guest("João", AnswerNo)},
+			after:  []Attendee{guest("Ana", AnswerYes), guest("João", AnswerYes)},
+			want:   []AttendanceChange{{Name: "João", From: AnswerNo, To: AnswerYes}},
+		},
+		{
+			name:   "acompanhante com o nome corrigido continua na mesma vaga",
+			before: []Attendee{guest("Ana", AnswerYes), companion("Pedro", AnswerYes)},
+			after:  []Attendee{guest("Ana", AnswerYes), companion("Pedro Lima", AnswerYes)},
+		},
+		{
+			name:   "acompanhante com o mesmo nome de um convidado",
+			before: []Attendee{guest("Ana", AnswerYes)},
+			after:  []Attendee{guest("Ana", AnswerNo), companion("Ana", AnswerYes)},
+			want:   []AttendanceChange{{Name: "Ana", From: AnswerYes, To: AnswerNo}, {Name: "Ana", To: AnswerYes}},
+		},
+		{
+			name:   "dois acompanhantes sem nome distinto",
+			before: []Attendee{guest("Ana", AnswerYes), companion("Acompanhante", AnswerYes)},
+			after:  []Attendee{guest("Ana", AnswerYes), companion("Acompanhante", AnswerYes), companion("Acompanhante", AnswerYes)},
+			want:   []AttendanceChange{{Name: "Acompanhante", To: AnswerYes}},
+		},
+		{
+			name:   "acompanhante removido",
+			before: []Attendee{guest("Ana", AnswerYes), companion("Pedro", AnswerYes)},
+			after:  []Attendee{guest("Ana", AnswerYes)},
+			want:   []AttendanceChange{{Name: "Pedro", From: AnswerYes}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diffAttendance(tt.before, tt.after); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diffAttendance = %+v, esperava %+v", got, tt.want)
+			}
+		})
+	}
+}
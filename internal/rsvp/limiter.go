@@ -0,0 +1,103 @@
+package rsvp
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limites por IP das rotas que recebem um código de convite. As consultas
+// limitam a taxa de tentativas; os códigos inválidos bloqueiam por mais tempo
+// quem está adivinhando códigos.
+const (
+	lookupLimit   = 30
+	lookupWindow  = time.Minute
+	failureLimit  = 10
+	failureWindow = 15 * time.Minute
+)
+
+// attemptLimiter conta tentativas por chave (IP) em janelas fixas
+type attemptLimiter struct {
+	limit     int
+	window    time.Duration
+	attempts  map[string]*attemptWindow
+	lastSweep time.Time
+	mutex     sync.Mutex
+}
+
+// attemptWindow é a contagem de uma chave na janela atual
+type attemptWindow struct {
+	count int
+	start time.Time
+}
+
+// newAttemptLimiter cria um limitador de limit tentativas por window
+func newAttemptLimiter(limit int, window time.Duration) *attemptLimiter {
+	return &attemptLimiter{
+		limit:    limit,
+		window:   window,
+		attempts: make(map[string]*attemptWindow),
+	}
+}
+
+// blocked indica se a chave esgotou as tentativas da janela atual e, nesse
+// caso, quanto tempo falta para a janela terminar
+func (l *attemptLimiter) blocked(key string) (time.Duration, bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	attempt, ok := l.attempts[key]
+	if !ok || now.Sub(attempt.start) >= l.window || attempt.count < l.limit {
+		return 0, false
+	}
+	return attempt.start.Add(l.window).Sub(now), true
+}
+
+// add registra uma tentativa da chave
+func (l *attemptLimiter) add(key string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	l.sweepLocked(now)
+
+	attempt, ok := l.attempts[key]
+	if !ok || now.Sub(attempt.start) >= l.window {
+		l.attempts[key] = &attemptWindow{count: 1, start: now}
+		return
+	}
+	attempt.count++
+}
+
+// sweepLocked descarta as janelas encerradas, no máximo uma vez por janela
+func (l *attemptLimiter) sweepLocked(now time.Time) {
+	if now.Sub(l.lastSweep) < l.window {
+		return
+	}
+	l.lastSweep = now
+	for key, attempt := range l.attempts {
+		if now.Sub(attempt.start) >= l.window {
+			delete(l.attempts, key)
+		}
+	}
+}
+
+// clientIP retorna o IP de quem fez a requisição. Atrás de um proxy
+// confiável, é o último endereço do X-Forwarded-For, o único acrescentado
+// pelo próprio proxy; os anteriores vêm do cliente e podem ser forjados.
+func clientIP(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		forwarded := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+		if ip := strings.TrimSpace(forwarded[len(forwarded)-1]); ip != "" {
+			return ip
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,214 @@
+// Package rsvp recebe e armazena as confirmações de presença dos convidados.
+package rsvp
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Respostas aceitas nas perguntas de sim/não do formulário
+const (
+	AnswerYes = "Sim"
+	AnswerNo  = "Não"
+)
+
+// Limites de tamanho dos campos de texto
+const (
+	maxNameLength    = 120
+	maxContactLength = 120
+)
+
+// Response é a confirmação de presença de um convite, com uma entrada para
+// cada pessoa (convidados nomeados e acompanhantes)
+type Response struct {
+	ID        string     `json:"id"`
+	Code      string     `json:"code"`
+	Name      string     `json:"name"`
+	Contact   string     `json:"contact,omitempty"`
+	Attendees []Attendee `json:"attendees"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+	History   []Change   `json:"history,omitempty"`
+}
+
+// Attendee é a resposta de uma pessoa do convite. Answers guarda as
+// respostas ao questionário (ver Form), que só se aplica a quem vai
+// comparecer.
+type Attendee struct {
+	Name      string            `json:"name"`
+	Companion bool              `json:"companion,omitempty"`
+	Attending string            `json:"attending"`
+	Answers   map[string]string `json:"answers,omitempty"`
+}
+
+// ValidationError lista os campos inválidos de uma resposta
+type ValidationError struct {
+	Fields map[string]string
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, 0, len(e.Fields))
+	for field, message := range e.Fields {
+		parts = append(parts, fmt.Sprintf("%s: %s", field, message))
+	}
+	return "resposta inválida: " + strings.Join(parts, "; ")
+}
+
+// Normalize remove espaços excedentes dos campos de texto e descarta as
+// respostas ao questionário de quem não vai comparecer
+func (r *Response) Normalize() {
+	r.Code = NormalizeCode(r.Code)
+	r.Name = strings.Join(strings.Fields(r.Name), " ")
+	r.Contact = strings.TrimSpace(r.Contact)
+	for i := range r.Attendees {
+		r.Attendees[i].normalize()
+	}
+}
+
+func (a *Attendee) normalize() {
+	a.Name = strings.Join(strings.Fields(a.Name), " ")
+	a.Attending = strings.TrimSpace(a.Attending)
+
+	if a.Attending == AnswerNo {
+		a.Answers = nil
+		return
+	}
+	for id, value := range a.Answers {
+		a.Answers[id] = strings.TrimSpace(value)
+	}
+}
+
+// clone retorna uma cópia da resposta que não compartilha as entradas nem o
+// histórico
+func (r *Response) clone() *Response {
+	copied := *r
+	copied.Attendees = make([]Attendee, len(r.Attendees))
+	for i, attendee := range r.Attendees {
+		if attendee.Answers != nil {
+			answers := make(map[string]string, len(attendee.Answers))
+			for id, value := range attendee.Answers {
+				answers[id] = value
+			}
+			attendee.Answers = answers
+		}
+		copied.Attendees[i] = attendee
+	}
+	copied.History = append([]Change(nil), r.History...)
+	return &copied
+}
+
+// AttendingCount retorna quantas pessoas do convite vão comparecer
+func (r *Response) AttendingCount() int {
+	count := 0
+	for _, attendee := range r.Attendees {
+		if attendee.Attending == AnswerYes {
+			count++
+		}
+	}
+	return count
+}
+
+// Validate confere os campos obrigatórios e os valores aceitos para o
+// convite e o questionário informados: cada convidado nomeado responde uma
+// única vez e os acompanhantes não passam do limite. Retorna um
+// *ValidationError com todos os problemas.
+func (r *Response) Validate(household *Household, form *Form) error {
+	fields := make(map[string]string)
+
+	if utf8.RuneCountInString(r.Contact) > maxContactLength {
+		fields["contact"] = fmt.Sprintf("use no máximo %d caracteres", maxContactLength)
+	}
+
+	answered := make(map[string]bool)
+	companions := 0
+	for i := range r.Attendees {
+		attendee := &r.Attendees[i]
+		prefix := fmt.Sprintf("attendees[%d].", i)
+
+		if attendee.Companion {
+			companions++
+		} else if attendee.Name != "" {
+			guest, ok := findGuest(household.Guests, attendee.Name)
+			switch {
+			case !ok:
+				fields[prefix+"name"] = fmt.Sprintf("%s não está no convite", attendee.Name)
+			case answered[guest]:
+				fields[prefix+"name"] = fmt.Sprintf("%s respondeu mais de uma vez", guest)
+			default:
+				answered[guest] = true
+				attendee.Name = guest
+			}
+		}
+
+		attendee.validate(prefix, form, fields)
+	}
+
+	switch {
+	case len(r.Attendees) == 0:
+		fields["attendees"] = "responda por ao menos uma pessoa do convite"
+	case companions > household.MaxCompanions:
+		fields["attendees"] = fmt.Sprintf("o convite permite no máximo %d acompanhante(s)", household.MaxCompanions)
+	default:
+		for _, guest := range household.Guests {
+			if !answered[guest] {
+				fields["attendees"] = fmt.Sprintf("responda por %s", guest)
+				break
+			}
+		}
+	}
+
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+// validate confere as respostas de uma pessoa, registrando os problemas em
+// fields com o prefixo informado
+func (a *Attendee) validate(prefix string, form *Form, fields map[string]string) {
+	switch {
+	case a.Name == "":
+		fields[prefix+"name"] = "informe o nome"
+	case utf8.RuneCountInString(a.Name) > maxNameLength:
+		fields[prefix+"name"] = fmt.Sprintf("use no máximo %d caracteres", maxNameLength)
+	}
+
+	if !isYesNo(a.Attending) {
+		fields[prefix+"attending"] = "responda Sim ou Não"
+		return
+	}
+	if a.Attending == AnswerNo {
+		return
+	}
+
+	if a.Answers == nil {
+		a.Answers = make(map[string]string)
+	}
+	form.validateAnswers(prefix+"answers.", a.Answers, fields)
+}
+
+// findGuest procura o nome entre os convidados do convite, sem diferenciar
+// maiúsculas, e retorna o nome como está cadastrado
+func findGuest(guests []string, name string) (string, bool) {
+	for _, guest := range guests {
+		if strings.EqualFold(guest, name) {
+			return guest, true
+		}
+	}
+	return "", false
+}
+
+func isYesNo(value string) bool {
+	return value == AnswerYes || value == AnswerNo
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,301 @@
+package rsvp
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kollinn/casamento-mari-kollinn/internal/fileutil"
+)
+
+// ErrNotFound indica que não existe resposta com o ID ou convite informado
+var ErrNotFound = errors.New("resposta não encontrada")
+
+// ErrAlreadyAnswered indica que o convite já tem uma resposta, que deve ser
+// alterada em vez de enviada de novo
+var ErrAlreadyAnswered = errors.New("o convite já foi respondido")
+
+// Store guarda as respostas em um arquivo JSON. Cada alteração regrava o
+// arquivo inteiro de forma atômica, então nenhuma confirmação é perdida se o
+// servidor for interrompido no meio de uma gravação.
+type Store struct {
+	dataFile  string
+	responses map[string]*Response
+	mutex     sync.RWMutex
+}
+
+// NewStore carrega as respostas do arquivo informado. Um arquivo ilegível é
+// um erro: sobrescrevê-lo apagaria as confirmações já recebidas.
+func NewStore(dataFile string) (*Store, error) {
+	store := &Store{
+		dataFile:  dataFile,
+		responses: make(map[string]*Response),
+	}
+
+	data, err := os.ReadFile(dataFile)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler confirmações de presença: %v", err)
+	}
+
+	var responses []*Response
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &responses); err != nil {
+			return nil, fmt.Errorf("erro ao decodificar confirmações de presença: %v", err)
+		}
+	}
+	for _, response := range responses {
+		store.responses[response.ID] = response
+	}
+
+	log.Printf("Carregadas %d confirmações de presença", len(store.responses))
+	return store, nil
+}
+
+// Create valida e grava a resposta do convite informado, preenchendo ID,
+// data de criação e o nome do convite. Cada convite tem uma única resposta:
+// se já houver uma, retorna ErrAlreadyAnswered.
+func (s *Store) Create(response *Response, household *Household, form *Form) error {
+	response.Normalize()
+	response.Code = household.Code
+	response.Name = household.Name
+	if err := response.Validate(household, form); err != nil {
+		return err
+	}
+
+	id, err := newID()
+	if err != nil {
+		return fmt.Errorf("erro ao gerar ID da resposta: %v", err)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.findByCodeLocked(household.Code) != nil {
+		return ErrAlreadyAnswered
+	}
+
+	stored := response.clone()
+	stored.ID = id
+	stored.CreatedAt = time.Now().UTC()
+	stored.UpdatedAt = nil
+	stored.History = []Change{{
+		At:         stored.CreatedAt,
+		Action:     ActionCreated,
+		Attendance: diffAttendance(nil, stored.Attendees),
+	}}
+
+	if err := s.replaceLocked(nil, stored); err != nil {
+		return err
+	}
+	*response = *stored.clone()
+	return nil
+}
+
+// Update substitui as respostas do convite informado, mantendo ID, data de
+// criação e histórico, e registra a alteração. Retorna ErrNotFound se o
+// convite ainda não foi respondido.
+func (s *Store) Update(response *Response, household *Household, form *Form) error {
+	response.Normalize()
+	if err := response.Validate(household, form); err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	previous := s.findByCodeLocked(household.Code)
+	if previous == nil {
+		return ErrNotFound
+	}
+
+	updated := previous.clone()
+	updated.Name = household.Name
+	updated.Contact = response.Contact
+	updated.Attendees = response.clone().Attendees
+	updated.touch(ActionUpdated, previous.Attendees)
+
+	if err := s.replaceLocked(previous, updated); err != nil {
+		return err
+	}
+	*response = *updated.clone()
+	return nil
+}
+
+// Withdraw cancela a resposta do convite informado: todas as pessoas passam
+// a não comparecer e a alteração fica registrada. Se ninguém comparecia, a
+// resposta é devolvida sem alteração. Retorna ErrNotFound se o convite ainda
+// não foi respondido.
+func (s *Store) Withdraw(household *Household) (*Response, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	previous := s.findByCodeLocked(household.Code)
+	if previous == nil {
+		return nil, ErrNotFound
+	}
+
+	// Uma resposta já cancelada não muda e não ganha outra entrada no histórico
+	if previous.withdrawn() {
+		return previous.clone(), nil
+	}
+
+	updated := previous.clone()
+	for i := range updated.Attendees {
+		updated.Attendees[i].Attending = AnswerNo
+		updated.Attendees[i].Answers = nil
+	}
+	updated.touch(ActionWithdrawn, previous.Attendees)
+
+	if err := s.replaceLocked(previous, updated); err != nil {
+		return nil, err
+	}
+	return updated.clone(), nil
+}
+
+// FindByCode retorna uma cópia da resposta do convite informado
+func (s *Store) FindByCode(code string) (*Response, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	response := s.findByCodeLocked(NormalizeCode(code))
+	if response == nil {
+		return nil, ErrNotFound
+	}
+	return response.clone(), nil
+}
+
+// Changes retorna as alterações registradas a partir de since, da mais
+// antiga para a mais recente
+func (s *Store) Changes(since time.Time) []HouseholdChange {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var changes []HouseholdChange
+	for _, response := range s.responses {
+		for _, change := range response.History {
+			if !change.At.Before(since) {
+				changes = append(changes, HouseholdChange{Code: response.Code, Name: response.Name, Change: change})
+			}
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].At.Before(changes[j].At)
+	})
+	return changes
+}
+
+// withdrawn indica se ninguém do convite comparece, sem respostas guardadas
+func (r *Response) withdrawn() bool {
+	for _, attendee := range r.Attendees {
+		if attendee.Attending != AnswerNo || len(attendee.Answers) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// touch registra no histórico uma alteração feita agora
+func (r *Response) touch(action string, before []Attendee) {
+	now := time.Now().UTC()
+	r.UpdatedAt = &now
+	r.History = append(r.History, Change{
+		At:         now,
+		Action:     action,
+		Attendance: diffAttendance(before, r.Attendees),
+	})
+}
+
+// findByCodeLocked retorna a resposta do convite, ou nil se ele ainda não
+// foi respondido. Deve ser chamado com o mutex travado.
+func (s *Store) findByCodeLocked(code string) *Response {
+	for _, response := range s.responses {
+		if response.Code == code {
+			return response
+		}
+	}
+	return nil
+}
+
+// replaceLocked troca (ou inclui, com previous nil) uma resposta e grava o
+// arquivo, desfazendo a troca se a gravação falhar. Deve ser chamado com o
+// mutex travado.
+func (s *Store) replaceLocked(previous, updated *Response) error {
+	s.responses[updated.ID] = updated
+	if err := s.saveLocked(); err != nil {
+		if previous != nil {
+			s.responses[previous.ID] = previous
+		} else {
+			delete(s.responses, updated.ID)
+		}
+		return err
+	}
+	return nil
+}
+
+// Get retorna uma cópia da resposta com o ID informado
+func (s *Store) Get(id string) (*Response, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	response, ok := s.responses[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return response.clone(), nil
+}
+
+// List retorna todas as respostas, da mais antiga para a mais recente
+func (s *Store) List() []Response {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.sortedLocked()
+}
+
+// sortedLocked deve ser chamado com o mutex travado
+func (s *Store) sortedLocked() []Response {
+	responses := make([]Response, 0, len(s.responses))
+	for _, response := range s.responses {
+		responses = append(responses, *response)
+	}
+	sort.Slice(responses, func(i, j int) bool {
+		if !responses[i].CreatedAt.Equal(responses[j].CreatedAt) {
+			return responses[i].CreatedAt.Before(responses[j].CreatedAt)
+		}
+		return responses[i].ID < responses[j].ID
+	})
+	return responses
+}
+
+// saveLocked grava todas as respostas. Deve ser chamado com o mutex travado.
+func (s *Store) saveLocked() error {
+	data, err := json.MarshalIndent(s.sortedLocked(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("erro ao codificar confirmações de presença: %v", err)
+	}
+
+	// Os dados pessoais dos convidados ficam legíveis apenas pelo servidor
+	if err := fileutil.WriteFileAtomic(s.dataFile, data, 0600); err != nil {
+		return fmt.Errorf("erro ao salvar confirmações de presença: %v", err)
+	}
+	return nil
+}
+
+// newID gera um identificador aleatório, impossível de adivinhar
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
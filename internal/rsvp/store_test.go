@@ -0,0 +1,215 @@
+package rsvp
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// attendees monta as entradas da resposta do testHousehold
+func attendees(ana, joao string, companions ...string) []Attendee {
+	menu := func(attending string) map[string]string {
+		if attending != AnswerYes {
+			return nil
+		}
+		return map[string]string{"vegetariano": "Sim", "frutosDoMar": "Sim"}
+	}
+	list := []Attendee{
+		{Name: "Ana Silva", Attending: ana, Answers: menu(ana)},
+		{Name: "João Silva", Attending: joao, Answers: menu(joao)},
+	}
+	for _, name := range companions {
+		list = append(list, Attendee{Name: name, Companion: true, Attending: AnswerYes, Answers: menu(AnswerYes)})
+	}
+	return list
+}
+
+func TestStoreLifecycle(t *testing.T) {
+	dataFile := filepath.Join(t.TempDir(), "rsvp.json")
+	store, err := NewStore(dataFile)
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	form := defaultForm(t)
+
+	steps := []struct {
+		name           string
+		apply          func() (*Response, error)
+		wantErr        error
+		wantAction     string // última ação do histórico
+		wantHistory    int
+		wantAttendance []AttendanceChange
+	}{
+		{
+			name: "alterar antes de responder",
+			apply: func() (*Response, error) {
+				response := &Response{Attendees: attendees(AnswerYes, AnswerNo)}
+				return response, store.Update(response, testHousehold, form)
+			},
+			wantErr: ErrNotFound,
+		},
+		{
+			name: "cancelar antes de responder",
+			apply: func() (*Response, error) {
+				return store.Withdraw(testHousehold)
+			},
+			wantErr: ErrNotFound,
+		},
+		{
+			name: "criar",
+			apply: func() (*Response, error) {
+				response := &Response{Attendees: attendees(AnswerYes, AnswerNo)}
+				return response, store.Create(response, testHousehold, form)
+			},
+			wantAction:  ActionCreated,
+			wantHistory: 1,
+			wantAttendance: []AttendanceChange{
+				{Name: "Ana Silva", To: AnswerYes},
+				{Name: "João Silva", To: AnswerNo},
+			},
+		},
+		{
+			name: "criar de novo",
+			apply: func() (*Response, error) {
+				response := &Response{Attendees: attendees(AnswerYes, AnswerYes)}
+				return response, store.Create(response, testHousehold, form)
+			},
+			wantErr: ErrAlreadyAnswered,
+		},
+		{
+			name: "resposta inválida não altera",
+			apply: func() (*Response, error) {
+				response := &Response{Attendees: attendees(AnswerYes, "Talvez")}
+				return response, store.Update(response, testHousehold, form)
+			},
+			wantErr: &ValidationError{},
+		},
+		{
+			name: "alterar presença e incluir acompanhante",
+			apply: func() (*Response, error) {
+				response := &Response{Attendees: attendees(AnswerYes, AnswerYes, "Pedro Lima")}
+				return response, store.Update(response, testHousehold, form)
+			},
+			wantAction:  ActionUpdated,
+			wantHistory: 2,
+			wantAttendance: []AttendanceChange{
+				{Name: "João Silva", From: AnswerNo, To: AnswerYes},
+				{Name: "Pedro Lima", To: AnswerYes},
+			},
+		},
+		{
+			name: "alterar só o cardápio",
+			apply: func() (*Response, error) {
+				response := &Response{Attendees: attendees(AnswerYes, AnswerYes, "Pedro Lima")}
+				response.Attendees[0].Answers["allergies"] = "amendoim"
+				return response, store.Update(response, testHousehold, form)
+			},
+			wantAction:  ActionUpdated,
+			wantHistory: 3,
+		},
+		{
+			name: "cancelar",
+			apply: func() (*Response, error) {
+				return store.Withdraw(testHousehold)
+			},
+			wantAction:  ActionWithdrawn,
+			wantHistory: 4,
+			wantAttendance: []AttendanceChange{
+				{Name: "Ana Silva", From: AnswerYes, To: AnswerNo},
+				{Name: "João Silva", From: AnswerYes, To: AnswerNo},
+				{Name: "Pedro Lima", From: AnswerYes, To: AnswerNo},
+			},
+		},
+		{
+			name: "cancelar de novo não registra outra alteração",
+			apply: func() (*Response, error) {
+				return store.Withdraw(testHousehold)
+			},
+			wantAction:  ActionWithdrawn,
+			wantHistory: 4,
+			wantAttendance: []AttendanceChange{
+				{Name: "Ana Silva", From: AnswerYes, To: AnswerNo},
+				{Name: "João Silva", From: AnswerYes, To: AnswerNo},
+				{Name: "Pedro Lima", From: AnswerYes, To: AnswerNo},
+			},
+		},
+	}
+
+	for _, step := range steps {
+		response, err := step.apply()
+		if step.wantErr != nil {
+			var validationErr *ValidationError
+			if _, wantValidation := step.wantErr.(*ValidationError); wantValidation {
+				if !errors.As(err, &validationErr) {
+					t.Errorf("%s: esperava erro de validação, obteve %v", step.name, err)
+				}
+			} else if !errors.Is(err, step.wantErr) {
+				t.Errorf("%s: esperava %v, obteve %v", step.name, step.wantErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+
+		if response.Code != testHousehold.Code || response.Name != testHousehold.Name || response.ID == "" {
+			t.Errorf("%s: resposta sem código, nome ou ID: %+v", step.name, response)
+		}
+		if len(response.History) != step.wantHistory {
+			t.Fatalf("%s: histórico com %d entradas, esperava %d", step.name, len(response.History), step.wantHistory)
+		}
+		last := response.History[len(response.History)-1]
+		if last.Action != step.wantAction {
+			t.Errorf("%s: última ação %q, esperava %q", step.name, last.Action, step.wantAction)
+		}
+		if !reflect.DeepEqual(last.Attendance, step.wantAttendance) {
+			t.Errorf("%s: mudanças de presença = %+v, esperava %+v", step.name, last.Attendance, step.wantAttendance)
+		}
+	}
+
+	// Depois do cancelamento, ninguém comparece nem guarda respostas
+	saved, err := store.FindByCode(testHousehold.Code)
+	if err != nil {
+		t.Fatalf("FindByCode: %v", err)
+	}
+	if saved.AttendingCount() != 0 || saved.UpdatedAt == nil {
+		t.Errorf("resposta cancelada: %+v", saved)
+	}
+	for _, attendee := range saved.Attendees {
+		if attendee.Answers != nil {
+			t.Errorf("%s manteve as respostas depois do cancelamento: %v", attendee.Name, attendee.Answers)
+		}
+	}
+
+	// O arquivo relido traz a mesma resposta, com o histórico
+	reloaded, err := NewStore(dataFile)
+	if err != nil {
+		t.Fatalf("NewStore ao reler: %v", err)
+	}
+	again, err := reloaded.FindByCode(testHousehold.Code)
+	if err != nil {
+		t.Fatalf("FindByCode depois de reler: %v", err)
+	}
+	if !reflect.DeepEqual(again, saved) {
+		t.Errorf("resposta relida difere da gravada:\n%+v\n%+v", again, saved)
+	}
+	if changes := reloaded.Changes(time.Time{}); len(changes) != 4 || changes[0].Action != ActionCreated {
+		t.Errorf("Changes depois de reler = %+v", changes)
+	}
+	if changes := reloaded.Changes(time.Now().Add(time.Minute)); len(changes) != 0 {
+		t.Errorf("Changes a partir do futuro = %+v", changes)
+	}
+}
+
+func TestNewStoreRejectsUnreadableFile(t *testing.T) {
+	dataFile := filepath.Join(t.TempDir(), "rsvp.json")
+	if err := os.WriteFile(dataFile, []byte("{não é json"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewStore(dataFile); err == nil {
+		t.Error("um arquivo ilegível deveria ser recusado em vez de sobrescrito")
+	}
+}
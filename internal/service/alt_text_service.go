@@ -2,11 +2,12 @@ package service
 
 import (
 	"encoding/json"
-	"io/ioutil"
 	"log"
 	"os"
 	"sync"
 
+	"github.com/kollinn/casamento-mari-kollinn/internal/config"
+	"github.com/kollinn/casamento-mari-kollinn/internal/fileutil"
 	"github.com/kollinn/casamento-mari-kollinn/internal/model"
 )
 
@@ -19,18 +20,22 @@ type AltTextService struct {
 	dataFile    string
 	mutex       sync.RWMutex
 	initialized bool
+
+	// dirty indica alterações ainda não gravadas; saveMutex serializa as gravações
+	dirty     bool
+	saveMutex sync.Mutex
 }
 
 // NewAltTextService cria uma nova instância do AltTextService
-func NewAltTextService() *AltTextService {
+func NewAltTextService(cfg *config.Config) *AltTextService {
 	service := &AltTextService{
 		altTexts:    make(AltTextMap),
-		dataFile:    "./data/alt_texts.json",
+		dataFile:    cfg.DataPath("alt_texts.json"),
 		initialized: false,
 	}
 
 	// Assegura que o diretório data existe
-	os.MkdirAll("./data", 0755)
+	os.MkdirAll(cfg.DataDir, 0755)
 
 	// Carrega os textos alternativos existentes
 	service.loadAltTexts()
@@ -52,7 +57,7 @@ func (ats *AltTextService) loadAltTexts() error {
 	}
 
 	// Lê o arquivo
-	data, err := ioutil.ReadFile(ats.dataFile)
+	data, err := os.ReadFile(ats.dataFile)
 	if err != nil {
 		log.Printf("Erro ao ler arquivo de textos alternativos: %v", err)
 		return err
@@ -75,27 +80,51 @@ func (ats *AltTextService) loadAltTexts() error {
 	return nil
 }
 
-// saveAltTexts salva os textos alternativos no arquivo
+// saveAltTexts salva os textos alternativos no arquivo, se houver alterações
+// pendentes. Em caso de erro, as alterações continuam pendentes.
 func (ats *AltTextService) saveAltTexts() error {
-	ats.mutex.RLock()
-	defer ats.mutex.RUnlock()
+	ats.saveMutex.Lock()
+	defer ats.saveMutex.Unlock()
 
-	data, err := json.MarshalIndent(ats.altTexts, "", "  ")
-	if err != nil {
-		log.Printf("Erro ao codificar textos alternativos: %v", err)
-		return err
+	ats.mutex.Lock()
+	if !ats.dirty {
+		ats.mutex.Unlock()
+		return nil
 	}
+	data, err := json.MarshalIndent(ats.altTexts, "", "  ")
+	count := len(ats.altTexts)
+	ats.dirty = false
+	ats.mutex.Unlock()
 
-	err = ioutil.WriteFile(ats.dataFile, data, 0644)
+	if err == nil {
+		// Gravação atômica: uma interrupção no meio não corrompe o arquivo
+		err = fileutil.WriteFileAtomic(ats.dataFile, data, 0644)
+	}
 	if err != nil {
+		ats.mutex.Lock()
+		ats.dirty = true
+		ats.mutex.Unlock()
 		log.Printf("Erro ao salvar textos alternativos: %v", err)
 		return err
 	}
 
-	log.Printf("Textos alternativos salvos com sucesso: %d entradas", len(ats.altTexts))
+	log.Printf("Textos alternativos salvos com sucesso: %d entradas", count)
 	return nil
 }
 
+// Close grava as alterações pendentes. Deve ser chamado no encerramento do
+// servidor, depois que as requisições em andamento terminarem.
+func (ats *AltTextService) Close() error {
+	return ats.saveAltTexts()
+}
+
+// Ready indica se os textos alternativos foram carregados do arquivo
+func (ats *AltTextService) Ready() bool {
+	ats.mutex.RLock()
+	defer ats.mutex.RUnlock()
+	return ats.initialized
+}
+
 // GetAltText retorna o texto alternativo para uma imagem específica
 func (ats *AltTextService) GetAltText(imageID string) string {
 	ats.mutex.RLock()
@@ -111,16 +140,20 @@ func (ats *AltTextService) GetAltText(imageID string) string {
 func (ats *AltTextService) SetAltText(imageID, text string) error {
 	ats.mutex.Lock()
 	ats.altTexts[imageID] = text
+	ats.dirty = true
 	ats.mutex.Unlock()
 
 	return ats.saveAltTexts()
 }
 
-// EnrichImages adiciona textos alternativos às imagens do modelo
+// EnrichImages adiciona textos alternativos às imagens do modelo.
+// Retorna uma cópia para não alterar a lista em cache da fonte de imagens.
 func (ats *AltTextService) EnrichImagesWithAltText(images []model.DriveImage) []model.DriveImage {
 	ats.mutex.RLock()
 	defer ats.mutex.RUnlock()
 
+	images = append([]model.DriveImage(nil), images...)
+
 	// Para cada imagem, verifica se temos um texto alternativo personalizado
 	for i := range images {
 		if altText, ok := ats.altTexts[images[i].ID]; ok && altText != "" {
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/kollinn/casamento-mari-kollinn/internal/fileutil"
+	"github.com/kollinn/casamento-mari-kollinn/internal/model"
+)
+
+// CatalogSnapshot é a última lista de imagens obtida com sucesso, gravada em
+// disco para que a galeria funcione logo após reiniciar o servidor
+type CatalogSnapshot struct {
+	FolderID       string             `json:"folderId"`
+	FetchedAt      time.Time          `json:"fetchedAt"`
+	Images         []model.DriveImage `json:"images"`
+	Folders        map[string]string  `json:"folders,omitempty"`
+	StartPageToken string             `json:"startPageToken,omitempty"`
+}
+
+// CatalogStore lê e grava o snapshot do catálogo em um arquivo JSON
+type CatalogStore struct {
+	dataFile string
+}
+
+// NewCatalogStore cria um CatalogStore gravando no arquivo informado
+func NewCatalogStore(dataFile string) *CatalogStore {
+	return &CatalogStore{dataFile: dataFile}
+}
+
+// Load lê o snapshot do disco. Retorna nil, sem erro, se ele ainda não existe.
+func (cs *CatalogStore) Load() (*CatalogSnapshot, error) {
+	data, err := os.ReadFile(cs.dataFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler snapshot do catálogo: %v", err)
+	}
+
+	var snapshot CatalogSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("erro ao decodificar snapshot do catálogo: %v", err)
+	}
+	return &snapshot, nil
+}
+
+// Save grava o snapshot de forma atômica
+func (cs *CatalogStore) Save(snapshot *CatalogSnapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("erro ao codificar snapshot do catálogo: %v", err)
+	}
+
+	if err := fileutil.WriteFileAtomic(cs.dataFile, data, 0644); err != nil {
+		return fmt.Errorf("erro ao salvar snapshot do catálogo: %v", err)
+	}
+
+	log.Printf("Snapshot do catálogo salvo: %d imagens", len(snapshot.Images))
+	return nil
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+
+	"github.com/kollinn/casamento-mari-kollinn/internal/fileutil"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/drive/v3"
+)
+
+// ErrTokenRevoked indica que o refresh token foi revogado ou expirou e que é
+// preciso gerar um novo token.json com a ferramenta setup_auth
+var ErrTokenRevoked = errors.New("refresh token revogado ou expirado, execute setup_auth novamente")
+
+// ErrServiceAccountRejected indica que o Google recusou a chave da conta de
+// serviço (chave removida, conta desativada ou delegação não autorizada)
+var ErrServiceAccountRejected = errors.New("credencial da conta de serviço recusada pelo Google")
+
+// persistingTokenSource grava em disco os tokens renovados, para que um
+// reinício do servidor não volte a usar um access token expirado. Sem path,
+// apenas reporta o estado da autenticação (usado pela conta de serviço).
+type persistingTokenSource struct {
+	base    oauth2.TokenSource
+	path    string
+	onError func(error)
+	mutex   sync.Mutex
+	last    *oauth2.Token
+}
+
+// Token implementa oauth2.TokenSource
+func (ts *persistingTokenSource) Token() (*oauth2.Token, error) {
+	token, err := ts.base.Token()
+	if err != nil {
+		var retrieveErr *oauth2.RetrieveError
+		if errors.As(err, &retrieveErr) && isRejectedGrant(retrieveErr) {
+			if ts.path == "" {
+				err = fmt.Errorf("%w: %v", ErrServiceAccountRejected, err)
+			} else {
+				err = fmt.Errorf("%w: %v", ErrTokenRevoked, err)
+			}
+		}
+		ts.onError(err)
+		return nil, err
+	}
+	ts.onError(nil)
+
+	if ts.path == "" {
+		return token, nil
+	}
+
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+
+	if ts.last != nil && ts.last.AccessToken == token.AccessToken {
+		return token, nil
+	}
+
+	// O Google nem sempre devolve o refresh token na renovação
+	if token.RefreshToken == "" && ts.last != nil {
+		token.RefreshToken = ts.last.RefreshToken
+	}
+
+	if err := saveTokenFile(ts.path, token); err != nil {
+		log.Printf("Aviso: não foi possível salvar o token renovado: %v", err)
+	} else {
+		log.Printf("Token do Google Drive renovado e salvo em %s (expira em %s)", ts.path, token.Expiry.Format("02/01 15:04"))
+	}
+	ts.last = token
+
+	return token, nil
+}
+
+// loadTokenFile lê o token OAuth2 gerado pelo setup_auth
+func loadTokenFile(path string) (*oauth2.Token, error) {
+	tokenBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("não foi possível ler o token: %v", err)
+	}
+
+	token := &oauth2.Token{}
+	if err := json.Unmarshal(tokenBytes, token); err != nil {
+		return nil, fmt.Errorf("não foi possível analisar o token: %v", err)
+	}
+	return token, nil
+}
+
+// saveTokenFile grava o token de forma atômica, legível apenas pelo usuário
+func saveTokenFile(path string, token *oauth2.Token) error {
+	tokenJSON, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	return fileutil.WriteFileAtomic(path, tokenJSON, 0600)
+}
+
+// isRejectedGrant indica se o servidor de autorização recusou a credencial,
+// caso em que repetir a requisição não adianta
+func isRejectedGrant(err *oauth2.RetrieveError) bool {
+	code := err.ErrorCode
+	if code == "" {
+		// O fluxo JWT da conta de serviço não preenche ErrorCode
+		var body struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(err.Body, &body) == nil {
+			code = body.Error
+		}
+	}
+
+	switch code {
+	case "invalid_grant", "unauthorized_client", "invalid_client":
+		return true
+	}
+	return false
+}
+
+// serviceAccountTokenSource cria a fonte de tokens a partir da chave JSON de
+// uma conta de serviço. Com subject, a conta age em nome desse usuário do
+// Workspace (delegação em todo o domínio).
+func serviceAccountTokenSource(ctx context.Context, keyPath, subject string) (oauth2.TokenSource, error) {
+	keyBytes, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("não foi possível ler a chave da conta de serviço: %v", err)
+	}
+
+	config, err := google.JWTConfigFromJSON(keyBytes, drive.DriveReadonlyScope)
+	if err != nil {
+		return nil, fmt.Errorf("não foi possível analisar a chave da conta de serviço: %v", err)
+	}
+	config.Subject = subject
+
+	if subject != "" {
+		log.Printf("Autenticando no Google Drive com a conta de serviço %s em nome de %s", config.Email, subject)
+	} else {
+		log.Printf("Autenticando no Google Drive com a conta de serviço %s", config.Email)
+	}
+
+	return config.TokenSource(ctx), nil
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func TestPersistingTokenSourceSavesRenewedTokenToDataDir(t *testing.T) {
+	configDir := t.TempDir()
+	dataDir := t.TempDir()
+	original := &oauth2.Token{AccessToken: "antigo", RefreshToken: "refresh"}
+	if err := saveTokenFile(filepath.Join(configDir, "token.json"), original); err != nil {
+		t.Fatalf("saveTokenFile: %v", err)
+	}
+	ds := &DriveService{
+		tokenFilePath: filepath.Join(configDir, "token.json"),
+		renewedPath:   filepath.Join(dataDir, "drive_token.json"),
+	}
+	source := &persistingTokenSource{
+		base:    oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "novo", Expiry: time.Now().Add(time.Hour)}),
+		path:    ds.renewedPath,
+		onError: func(error) {},
+		last:    original,
+	}
+	if _, err := source.Token(); err != nil {
+		t.Fatalf("Token: %v", err)
+	}
+
+	saved, err := loadTokenFile(ds.renewedPath)
+	if err != nil {
+		t.Fatalf("token renovado não foi salvo em DATA_DIR: %v", err)
+	}
+	if saved.AccessToken != "novo" || saved.RefreshToken != "refresh" {
+		t.Errorf("token salvo = %+v, esperava o access token novo com o refresh token anterior", saved)
+	}
+	if got := ds.tokenFile(); got != ds.renewedPath {
+		t.Errorf("tokenFile = %s, esperava a cópia renovada", got)
+	}
+
+	// O token.json de CONFIG_DIR (somente leitura na Render) não é tocado
+	if kept, err := loadTokenFile(ds.tokenFilePath); err != nil || kept.AccessToken != "antigo" {
+		t.Errorf("token.json de CONFIG_DIR foi alterado: %+v, %v", kept, err)
+	}
+}
+
+func TestTokenFilePrefersNewerSetupAuthToken(t *testing.T) {
+	dir := t.TempDir()
+	ds := &DriveService{
+		tokenFilePath: filepath.Join(dir, "token.json"),
+		renewedPath:   filepath.Join(dir, "drive_token.json"),
+	}
+	token := &oauth2.Token{AccessToken: "a", RefreshToken: "r"}
+	saveTokenFile(ds.tokenFilePath, token)
+
+	if got := ds.tokenFile(); got != ds.tokenFilePath {
+		t.Errorf("sem cópia renovada, tokenFile = %s, esperava o token.json", got)
+	}
+
+	saveTokenFile(ds.renewedPath, token)
+	past := time.Now().Add(-time.Hour)
+	os.Chtimes(ds.tokenFilePath, past, past)
+	if got := ds.tokenFile(); got != ds.renewedPath {
+		t.Errorf("com cópia mais nova, tokenFile = %s, esperava a cópia renovada", got)
+	}
+
+	// Um setup_auth novo substitui a cópia renovada
+	os.Chtimes(ds.tokenFilePath, time.Now().Add(time.Hour), time.Now().Add(time.Hour))
+	if got := ds.tokenFile(); got != ds.tokenFilePath {
+		t.Errorf("com token.json mais novo, tokenFile = %s, esperava o token.json", got)
+	}
+}
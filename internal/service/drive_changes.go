@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/kollinn/casamento-mari-kollinn/internal/model"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+)
+
+// Sync atualiza o cache aplicando apenas as mudanças ocorridas no Drive desde
+// a última sincronização (API Changes). Sem cache ou token, quando o Drive
+// recusa o token, quando uma pasta do álbum é criada, renomeada ou removida,
+// ou quando os thumbnailLinks passam de driveThumbnailLinkTTL, faz a
+// listagem completa.
+func (ds *DriveService) Sync(ctx context.Context) error {
+	ds.refreshMutex.Lock()
+	defer ds.refreshMutex.Unlock()
+
+	ds.cacheLock.RLock()
+	pageToken := ds.startPageToken
+	initialized := ds.initialized
+	listedAt := ds.listedAt
+	ds.cacheLock.RUnlock()
+
+	if !initialized || pageToken == "" {
+		return ds.refreshLocked(ctx)
+	}
+	if time.Since(listedAt) > driveThumbnailLinkTTL {
+		log.Printf("Links de miniatura do Drive com mais de %s, refazendo a listagem completa", driveThumbnailLinkTTL)
+		return ds.refreshLocked(ctx)
+	}
+
+	srv, err := ds.driveAPI(ctx)
+	if err != nil {
+		ds.recordError(err)
+		return err
+	}
+
+	// Copia o estado atual para aplicar as mudanças fora do lock
+	ds.cacheLock.RLock()
+	catalog := &driveCatalog{
+		images:   append([]model.DriveImage(nil), ds.imageCache...),
+		folders:  make(map[string]string, len(ds.folders)),
+		listedAt: ds.listedAt,
+	}
+	for id, name := range ds.folders {
+		catalog.folders[id] = name
+	}
+	ds.cacheLock.RUnlock()
+
+	changed := false
+	needFullRefresh := false
+	for pageToken != "" {
+		changeList, err := srv.Changes.List(pageToken).
+			Fields("nextPageToken, newStartPageToken, changes(fileId, removed, file(" + driveFileFields + "))").
+			PageSize(drivePageSize).
+			IncludeRemoved(true).
+			Spaces("drive").
+			Context(ctx).
+			Do()
+		if isInvalidPageToken(err) {
+			// Token expirado ou de outra conta: recomeça com a listagem completa,
+			// que também obtém um token novo
+			log.Printf("Token de mudanças do Drive recusado (%v), refazendo a listagem completa", err)
+			return ds.refreshLocked(ctx)
+		}
+		if err != nil {
+			err = fmt.Errorf("erro ao listar mudanças do Drive: %v", err)
+			ds.recordError(err)
+			return err
+		}
+
+		for _, change := range changeList.Changes {
+			applied, structural := catalog.applyChange(change, ds.folderID)
+			changed = changed || applied
+			needFullRefresh = needFullRefresh || structural
+		}
+
+		if changeList.NewStartPageToken != "" {
+			catalog.startPageToken = changeList.NewStartPageToken
+		}
+		pageToken = changeList.NextPageToken
+	}
+
+	if needFullRefresh {
+		log.Printf("Estrutura de álbuns mudou no Drive, refazendo a listagem completa")
+		return ds.refreshLocked(ctx)
+	}
+
+	if !changed {
+		// Nada mudou: só avança o token e renova a validade do cache. O token
+		// novo vai para o snapshot, para que um reinício não reprocesse as
+		// mudanças de arquivos fora da galeria.
+		ds.cacheLock.Lock()
+		advanced := ds.startPageToken != catalog.startPageToken
+		ds.startPageToken = catalog.startPageToken
+		ds.fetchedAt = time.Now()
+		ds.lastError = nil
+		ds.cacheLock.Unlock()
+
+		if advanced {
+			ds.saveSnapshot()
+		}
+		return nil
+	}
+
+	sortImages(catalog.images)
+	ds.storeCatalog(catalog)
+	log.Printf("Cache sincronizado com as mudanças do Drive: %d imagens", len(catalog.images))
+	return nil
+}
+
+// isInvalidPageToken indica se o Drive recusou o token de mudanças. Como os
+// demais parâmetros do Changes.List são fixos, um 400, 404 ou 410 só pode vir
+// do token.
+func isInvalidPageToken(err error) bool {
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.Code {
+	case http.StatusBadRequest, http.StatusNotFound, http.StatusGone:
+		return true
+	}
+	return false
+}
+
+// applyChange aplica uma mudança ao catálogo. Retorna se o catálogo mudou e
+// se a mudança afeta a estrutura de pastas (exigindo listagem completa).
+func (c *driveCatalog) applyChange(change *drive.Change, rootID string) (changed bool, structural bool) {
+	file := change.File
+	removed := change.Removed || file == nil || file.Trashed
+
+	// Mudanças em pastas conhecidas ou novas subpastas alteram os álbuns
+	if _, known := c.folders[change.FileId]; known {
+		if change.FileId == rootID {
+			return false, removed
+		}
+		if removed || file.MimeType != driveFolderMimeType {
+			return false, true
+		}
+		parent := c.knownParent(file.Parents)
+		if parent == "" || albumName(c.folders[parent], file.Name) != c.folders[change.FileId] {
+			return false, true
+		}
+		return false, false
+	}
+	if !removed && file.MimeType == driveFolderMimeType {
+		return false, c.knownParent(file.Parents) != ""
+	}
+
+	// Imagem removida, movida para fora dos álbuns ou que deixou de ser imagem
+	parent := ""
+	if !removed {
+		parent = c.knownParent(file.Parents)
+	}
+	if removed || parent == "" || !strings.HasPrefix(file.MimeType, "image/") {
+		return c.removeImage(change.FileId), false
+	}
+
+	// Imagem nova, renomeada, movida entre álbuns ou com descrição editada
+	image := driveFileToImage(file)
+	if parent != rootID {
+		image.AlbumID = parent
+		image.Album = c.folders[parent]
+	}
+	for i := range c.images {
+		if c.images[i].ID == image.ID {
+			if c.images[i] == image {
+				return false, false
+			}
+			c.images[i] = image
+			return true, false
+		}
+	}
+	c.images = append(c.images, image)
+	return true, false
+}
+
+// knownParent retorna o primeiro pai que é uma pasta conhecida da galeria
+func (c *driveCatalog) knownParent(parents []string) string {
+	for _, parent := range parents {
+		if _, ok := c.folders[parent]; ok {
+			return parent
+		}
+	}
+	return ""
+}
+
+// removeImage remove uma imagem do catálogo, se existir
+func (c *driveCatalog) removeImage(id string) bool {
+	for i := range c.images {
+		if c.images[i].ID == id {
+			c.images = append(c.images[:i], c.images[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
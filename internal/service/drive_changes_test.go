@@ -0,0 +1,346 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kollinn/casamento-mari-kollinn/internal/model"
+	"google.golang.org/api/drive/v3"
+)
+
+// driveImageFile cria uma foto JPEG dentro da pasta informada
+func driveImageFile(id, name, parent string) *drive.File {
+	return &drive.File{
+		Id:           id,
+		Name:         name,
+		MimeType:     "image/jpeg",
+		Parents:      []string{parent},
+		Md5Checksum:  "md5-" + id,
+		ModifiedTime: "2026-05-01T12:00:00Z",
+	}
+}
+
+// newSyncedDriveService carrega o catálogo inicial (foto na raiz e no álbum
+// "Festa") e deixa o serviço pronto para sincronizar a partir do token "1"
+func newSyncedDriveService(t *testing.T) (*fakeDrive, *DriveService) {
+	fake, server := newFakeDrive(t)
+	fake.putFile(&drive.File{Id: "festa", Name: "Festa", MimeType: driveFolderMimeType, Parents: []string{fakeDriveRootID}})
+	fake.putFile(driveImageFile("altar", "altar.jpg", fakeDriveRootID))
+	fake.putFile(driveImageFile("bolo", "bolo.jpg", "festa"))
+
+	ds := newTestDriveService(t, server)
+	if err := ds.Sync(context.Background()); err != nil {
+		t.Fatalf("carga inicial: %v", err)
+	}
+	if got := imageIDs(ds); len(got) != 2 {
+		t.Fatalf("carga inicial com %d imagens, esperava 2: %v", len(got), got)
+	}
+	return fake, ds
+}
+
+// imageIDs retorna as imagens em cache indexadas pelo ID
+func imageIDs(ds *DriveService) map[string]model.DriveImage {
+	ds.cacheLock.RLock()
+	defer ds.cacheLock.RUnlock()
+	images := make(map[string]model.DriveImage, len(ds.imageCache))
+	for _, image := range ds.imageCache {
+		images[image.ID] = image
+	}
+	return images
+}
+
+func currentPageToken(ds *DriveService) string {
+	ds.cacheLock.RLock()
+	defer ds.cacheLock.RUnlock()
+	return ds.startPageToken
+}
+
+func TestSyncAppliesIncrementalAdd(t *testing.T) {
+	fake, ds := newSyncedDriveService(t)
+	listings := fake.requestCount("GET /files")
+
+	danca := driveImageFile("danca", "danca.jpg", "festa")
+	danca.Description = "Primeira dança"
+	fake.addChanges("1", &drive.ChangeList{
+		NextPageToken: "2",
+		Changes:       []*drive.Change{{FileId: "danca", File: danca}},
+	})
+	fake.addChanges("2", &drive.ChangeList{
+		NewStartPageToken: "3",
+		Changes:           []*drive.Change{{FileId: "convite", File: driveImageFile("convite", "convite.jpg", fakeDriveRootID)}},
+	})
+
+	notified := 0
+	ds.OnRefresh(func() { notified++ })
+
+	if err := ds.Sync(context.Background()); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+
+	images := imageIDs(ds)
+	if len(images) != 4 {
+		t.Fatalf("esperava 4 imagens, obteve %d: %v", len(images), images)
+	}
+	if image := images["danca"]; image.AlbumID != "festa" || image.Album != "Festa" || image.AltText != "Primeira dança" {
+		t.Errorf("imagem nova com álbum ou texto alternativo errado: %+v", image)
+	}
+	if image := images["convite"]; image.AlbumID != "" {
+		t.Errorf("imagem da raiz não deveria ter álbum: %+v", image)
+	}
+	if token := currentPageToken(ds); token != "3" {
+		t.Errorf("token de mudanças = %q, esperava o newStartPageToken %q", token, "3")
+	}
+	if got := fake.requestCount("GET /files"); got != listings {
+		t.Errorf("a sincronização incremental refez a listagem (%d chamadas a files.list)", got-listings)
+	}
+	if notified != 1 {
+		t.Errorf("OnRefresh chamado %d vezes, esperava 1", notified)
+	}
+}
+
+func TestSyncAppliesIncrementalDelete(t *testing.T) {
+	fake, ds := newSyncedDriveService(t)
+
+	trashed := driveImageFile("altar", "altar.jpg", fakeDriveRootID)
+	trashed.Trashed = true
+	fake.addChanges("1", &drive.ChangeList{
+		NewStartPageToken: "2",
+		Changes: []*drive.Change{
+			{FileId: "bolo", Removed: true},
+			{FileId: "altar", File: trashed},
+		},
+	})
+
+	if err := ds.Sync(context.Background()); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+
+	if images := imageIDs(ds); len(images) != 0 {
+		t.Errorf("imagens removidas e na lixeira continuam no cache: %v", images)
+	}
+}
+
+func TestSyncAppliesMoves(t *testing.T) {
+	fake, ds := newSyncedDriveService(t)
+	listings := fake.requestCount("GET /files")
+
+	fake.addChanges("1", &drive.ChangeList{
+		NewStartPageToken: "2",
+		Changes: []*drive.Change{
+			// Da raiz para o álbum
+			{FileId: "altar", File: driveImageFile("altar", "altar.jpg", "festa")},
+			// Para uma pasta fora da galeria
+			{FileId: "bolo", File: driveImageFile("bolo", "bolo.jpg", "outra-pasta")},
+		},
+	})
+
+	if err := ds.Sync(context.Background()); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+
+	images := imageIDs(ds)
+	if image, ok := images["altar"]; !ok || image.AlbumID != "festa" || image.Album != "Festa" {
+		t.Errorf("imagem movida para o álbum: %+v", image)
+	}
+	if _, ok := images["bolo"]; ok {
+		t.Errorf("imagem movida para fora da galeria continua no cache")
+	}
+	if got := fake.requestCount("GET /files"); got != listings {
+		t.Errorf("mover imagens não deveria refazer a listagem")
+	}
+}
+
+func TestSyncRelistsWhenAlbumIsRenamed(t *testing.T) {
+	fake, ds := newSyncedDriveService(t)
+
+	renamed := &drive.File{Id: "festa", Name: "Recepção", MimeType: driveFolderMimeType, Parents: []string{fakeDriveRootID}}
+	fake.putFile(renamed)
+	fake.setStartPageToken("9")
+	fake.addChanges("1", &drive.ChangeList{
+		NewStartPageToken: "2",
+		Changes:           []*drive.Change{{FileId: "festa", File: renamed}},
+	})
+
+	if err := ds.Sync(context.Background()); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+
+	if image := imageIDs(ds)["bolo"]; image.Album != "Recepção" {
+		t.Errorf("álbum renomeado não foi aplicado: %+v", image)
+	}
+	if token := currentPageToken(ds); token != "9" {
+		t.Errorf("a listagem completa deveria usar o token novo, obteve %q", token)
+	}
+}
+
+func TestSyncFallsBackToFullListingWhenPageTokenIsRejected(t *testing.T) {
+	fake, ds := newSyncedDriveService(t)
+	listings := fake.requestCount("GET /files")
+
+	// Sem página publicada para o token "1", o Drive responde 400 (token inválido)
+	fake.putFile(driveImageFile("danca", "danca.jpg", "festa"))
+	fake.deleteFile("altar")
+	fake.setStartPageToken("7")
+
+	if err := ds.Sync(context.Background()); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+
+	if got := fake.requestCount("GET /files"); got == listings {
+		t.Fatal("token recusado deveria levar à listagem completa")
+	}
+	images := imageIDs(ds)
+	if _, ok := images["danca"]; !ok || len(images) != 2 {
+		t.Errorf("catálogo após a listagem completa: %v", images)
+	}
+	if token := currentPageToken(ds); token != "7" {
+		t.Errorf("token de mudanças = %q, esperava o novo %q", token, "7")
+	}
+	if status := ds.Status(); status.LastError != "" {
+		t.Errorf("a recuperação não deveria deixar erro registrado: %q", status.LastError)
+	}
+}
+
+func TestSyncWithoutChangesAdvancesToken(t *testing.T) {
+	fake, ds := newSyncedDriveService(t)
+	fake.addChanges("1", &drive.ChangeList{NewStartPageToken: "5"})
+
+	notified := 0
+	ds.OnRefresh(func() { notified++ })
+
+	if err := ds.Sync(context.Background()); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if token := currentPageToken(ds); token != "5" {
+		t.Errorf("token de mudanças = %q, esperava %q", token, "5")
+	}
+	if notified != 0 {
+		t.Errorf("sem mudanças, OnRefresh não deveria ser chamado")
+	}
+
+	snapshot, err := ds.catalogStore.Load()
+	if err != nil || snapshot == nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	if snapshot.StartPageToken != "5" {
+		t.Errorf("token no snapshot = %q, esperava %q", snapshot.StartPageToken, "5")
+	}
+}
+
+func TestRestoredSnapshotIsRevalidatedOnFirstRequest(t *testing.T) {
+	fake, server := newFakeDrive(t)
+	fake.putFile(driveImageFile("altar", "altar.jpg", fakeDriveRootID))
+
+	ds := newTestDriveService(t, server)
+	err := ds.catalogStore.Save(&CatalogSnapshot{
+		FolderID:       fakeDriveRootID,
+		FetchedAt:      time.Now(),
+		Images:         []model.DriveImage{{ID: "altar", Name: "altar.jpg", ThumbnailLink: "https://expirado.example/altar=s220"}},
+		Folders:        map[string]string{fakeDriveRootID: ""},
+		StartPageToken: "1",
+	})
+	if err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	ds.loadSnapshot()
+
+	// O snapshot é servido na hora, mesmo dentro do TTL do cache...
+	images, err := ds.GetDriveImages(context.Background())
+	if err != nil || len(images) != 1 {
+		t.Fatalf("GetDriveImages = %v, %v", images, err)
+	}
+
+	// ...mas a primeira requisição agenda a listagem completa que renova os links
+	waitForFullListing(t, ds)
+	if fake.requestCount("GET /files") == 0 {
+		t.Error("o snapshot restaurado não foi revalidado")
+	}
+}
+
+func TestRejectedThumbnailLinkSchedulesFullListing(t *testing.T) {
+	fake, ds := newSyncedDriveService(t)
+	listings := fake.requestCount("GET /files")
+
+	expired := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "link expirado", http.StatusForbidden)
+	}))
+	defer expired.Close()
+
+	ds.cacheLock.Lock()
+	for i := range ds.imageCache {
+		ds.imageCache[i].ThumbnailLink = expired.URL + "/" + ds.imageCache[i].ID + "=s220"
+	}
+	ds.cacheLock.Unlock()
+
+	if _, _, err := ds.OpenImage(context.Background(), "altar", ""); err == nil {
+		t.Fatal("esperava erro com o link recusado")
+	}
+
+	waitForFullListing(t, ds)
+	if got := fake.requestCount("GET /files"); got == listings {
+		t.Error("o link recusado deveria agendar a listagem completa")
+	}
+}
+
+// waitForFullListing espera a listagem completa agendada em segundo plano
+func waitForFullListing(t *testing.T, ds *DriveService) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		ds.cacheLock.RLock()
+		listed := !ds.listedAt.IsZero()
+		ds.cacheLock.RUnlock()
+		if listed {
+			// Espera a gravação do snapshot, feita ainda com refreshMutex travado
+			ds.refreshMutex.Lock()
+			ds.refreshMutex.Unlock()
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("a listagem completa não foi feita")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSyncRelistsWhenThumbnailLinksExpire(t *testing.T) {
+	fake, ds := newSyncedDriveService(t)
+	listings := fake.requestCount("GET /files")
+	fake.addChanges("1", &drive.ChangeList{NewStartPageToken: "2"})
+
+	// Dentro da validade dos links, só a API de mudanças é consultada
+	if err := ds.Sync(context.Background()); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if got := fake.requestCount("GET /files"); got != listings {
+		t.Fatalf("links recentes não deveriam refazer a listagem")
+	}
+
+	ds.cacheLock.Lock()
+	ds.listedAt = time.Now().Add(-driveThumbnailLinkTTL - time.Minute)
+	ds.cacheLock.Unlock()
+	fake.putFile(driveImageFile("danca", "danca.jpg", "festa"))
+	fake.setStartPageToken("8")
+
+	if err := ds.Sync(context.Background()); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if got := fake.requestCount("GET /files"); got == listings {
+		t.Fatal("links expirados deveriam levar à listagem completa")
+	}
+	if _, ok := imageIDs(ds)["danca"]; !ok {
+		t.Errorf("a listagem completa não trouxe a imagem nova")
+	}
+	ds.cacheLock.RLock()
+	age := time.Since(ds.listedAt)
+	ds.cacheLock.RUnlock()
+	if age > time.Minute {
+		t.Errorf("a listagem completa não renovou a idade dos links (%s)", age)
+	}
+	if token := currentPageToken(ds); token != "8" {
+		t.Errorf("token de mudanças = %q, esperava %q", token, "8")
+	}
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kollinn/casamento-mari-kollinn/internal/model"
+)
+
+// googleTokenInfoURL consulta os escopos e a validade de um access token
+const googleTokenInfoURL = "https://oauth2.googleapis.com/tokeninfo"
+
+// DriveTokenInfo descreve o token usado pelo servidor, conforme o Google
+type DriveTokenInfo struct {
+	ServiceAccount  bool
+	HasRefreshToken bool
+	Expiry          time.Time
+	Scopes          []string
+}
+
+// HasScope indica se o token concede o escopo informado
+func (ti *DriveTokenInfo) HasScope(scope string) bool {
+	for _, s := range ti.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// TokenInfo obtém um access token válido (renovando-o se preciso) e consulta
+// no Google os escopos concedidos a ele
+func (ds *DriveService) TokenInfo(ctx context.Context) (*DriveTokenInfo, error) {
+	if _, err := ds.driveAPI(ctx); err != nil {
+		return nil, err
+	}
+
+	ds.clientMutex.Lock()
+	tokenSource := ds.tokenSource
+	ds.clientMutex.Unlock()
+
+	token, err := tokenSource.Token()
+	if err != nil {
+		return nil, fmt.Errorf("erro ao obter access token: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		googleTokenInfoURL+"?access_token="+url.QueryEscape(token.AccessToken), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao consultar tokeninfo: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("tokeninfo recusou o token: status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Scope     string `json:"scope"`
+		ExpiresIn string `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("erro ao decodificar tokeninfo: %v", err)
+	}
+
+	info := &DriveTokenInfo{
+		ServiceAccount:  ds.serviceAccountFile != "",
+		HasRefreshToken: token.RefreshToken != "",
+		Expiry:          token.Expiry,
+		Scopes:          strings.Fields(body.Scope),
+	}
+	if seconds, err := strconv.Atoi(body.ExpiresIn); err == nil {
+		info.Expiry = time.Now().Add(time.Duration(seconds) * time.Second)
+	}
+	return info, nil
+}
+
+// CheckFolder confere se a pasta configurada existe, é uma pasta e pode ser
+// listada com as credenciais atuais. Retorna o nome da pasta.
+func (ds *DriveService) CheckFolder(ctx context.Context) (string, error) {
+	srv, err := ds.driveAPI(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	folder, err := srv.Files.Get(ds.folderID).
+		Fields("id, name, mimeType, trashed, capabilities(canListChildren)").
+		Context(ctx).
+		Do()
+	if err != nil {
+		return "", fmt.Errorf("pasta %s inacessível: %v", ds.folderID, err)
+	}
+
+	switch {
+	case folder.MimeType != driveFolderMimeType:
+		return "", fmt.Errorf("%s (%s) não é uma pasta", folder.Name, ds.folderID)
+	case folder.Trashed:
+		return "", fmt.Errorf("a pasta %s está na lixeira", folder.Name)
+	case folder.Capabilities != nil && !folder.Capabilities.CanListChildren:
+		return "", fmt.Errorf("sem permissão para listar a pasta %s", folder.Name)
+	}
+	return folder.Name, nil
+}
+
+// FetchImages lista as imagens direto do Drive, sem usar nem alterar o
+// cache e o snapshot do servidor
+func (ds *DriveService) FetchImages(ctx context.Context) ([]model.DriveImage, error) {
+	srv, err := ds.driveAPI(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	images, _, err := ds.listAlbumTree(ctx, srv)
+	if err != nil {
+		return nil, err
+	}
+	return images, nil
+}
@@ -0,0 +1,220 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/option"
+)
+
+// fakeDriveRootID é a pasta raiz da galeria no Drive de teste
+const fakeDriveRootID = "root-folder"
+
+// fakeDrive simula os recursos da API do Drive usados pelo DriveService:
+// files.list, files.get, changes.getStartPageToken, changes.list, changes.watch e
+// channels.stop
+type fakeDrive struct {
+	t *testing.T
+
+	mutex          sync.Mutex
+	files          map[string]*drive.File
+	startPageToken string
+	changePages    map[string]*drive.ChangeList // pageToken -> página de mudanças
+	watches        []*drive.Channel
+	stopped        []string
+	watchTTL       time.Duration  // quando definido, substitui a expiração pedida
+	failWatches    int            // quantos changes.watch seguintes respondem 500
+	requests       map[string]int // método e caminho -> quantidade
+}
+
+var parentQuery = regexp.MustCompile(`'([^']+)' in parents`)
+
+func newFakeDrive(t *testing.T) (*fakeDrive, *httptest.Server) {
+	fake := &fakeDrive{
+		t:              t,
+		files:          make(map[string]*drive.File),
+		startPageToken: "1",
+		changePages:    make(map[string]*drive.ChangeList),
+		requests:       make(map[string]int),
+	}
+	fake.putFile(&drive.File{Id: fakeDriveRootID, Name: "Casamento", MimeType: driveFolderMimeType})
+
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, server
+}
+
+func (f *fakeDrive) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.requests[r.Method+" "+r.URL.Path]++
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/files":
+		f.listFiles(w, r)
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/files/"):
+		file, ok := f.files[strings.TrimPrefix(r.URL.Path, "/files/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			writeJSON(w, map[string]interface{}{
+				"error": map[string]interface{}{"code": http.StatusNotFound, "message": "File not found"},
+			})
+			return
+		}
+		writeJSON(w, &drive.File{Id: file.Id})
+	case r.Method == http.MethodGet && r.URL.Path == "/changes/startPageToken":
+		writeJSON(w, &drive.StartPageToken{StartPageToken: f.startPageToken})
+	case r.Method == http.MethodGet && r.URL.Path == "/changes":
+		page, ok := f.changePages[r.URL.Query().Get("pageToken")]
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			writeJSON(w, map[string]interface{}{
+				"error": map[string]interface{}{
+					"code":    http.StatusBadRequest,
+					"message": "Invalid Value",
+					"errors":  []map[string]string{{"reason": "invalid", "location": "pageToken"}},
+				},
+			})
+			return
+		}
+		writeJSON(w, page)
+	case r.Method == http.MethodPost && r.URL.Path == "/changes/watch":
+		if f.failWatches > 0 {
+			f.failWatches--
+			http.Error(w, "backend error", http.StatusInternalServerError)
+			return
+		}
+		var channel drive.Channel
+		if err := json.NewDecoder(r.Body).Decode(&channel); err != nil {
+			f.t.Errorf("corpo do changes.watch inválido: %v", err)
+		}
+		if got := r.URL.Query().Get("pageToken"); got != f.startPageToken {
+			f.t.Errorf("changes.watch com pageToken %q, esperava %q", got, f.startPageToken)
+		}
+		f.watches = append(f.watches, &channel)
+		expiration := channel.Expiration
+		if f.watchTTL > 0 {
+			expiration = time.Now().Add(f.watchTTL).UnixMilli()
+		}
+		writeJSON(w, &drive.Channel{
+			Id:         channel.Id,
+			ResourceId: "resource-" + channel.Id,
+			Expiration: expiration,
+		})
+	case r.Method == http.MethodPost && r.URL.Path == "/channels/stop":
+		var channel drive.Channel
+		json.NewDecoder(r.Body).Decode(&channel)
+		if channel.ResourceId != "resource-"+channel.Id {
+			f.t.Errorf("channels.stop do canal %s com resourceId %q", channel.Id, channel.ResourceId)
+		}
+		f.stopped = append(f.stopped, channel.Id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		f.t.Errorf("requisição inesperada ao Drive: %s %s", r.Method, r.URL)
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeDrive) listFiles(w http.ResponseWriter, r *http.Request) {
+	match := parentQuery.FindStringSubmatch(r.URL.Query().Get("q"))
+	if match == nil {
+		f.t.Errorf("files.list sem pasta pai: %s", r.URL.Query().Get("q"))
+		http.Error(w, "q inválida", http.StatusBadRequest)
+		return
+	}
+
+	list := &drive.FileList{}
+	for _, file := range f.files {
+		if file.Trashed {
+			continue
+		}
+		for _, parent := range file.Parents {
+			if parent == match[1] {
+				list.Files = append(list.Files, file)
+				break
+			}
+		}
+	}
+	writeJSON(w, list)
+}
+
+// putFile cria ou substitui um arquivo no Drive de teste
+func (f *fakeDrive) putFile(file *drive.File) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.files[file.Id] = file
+}
+
+// deleteFile remove um arquivo do Drive de teste
+func (f *fakeDrive) deleteFile(id string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	delete(f.files, id)
+}
+
+// setStartPageToken define o token devolvido por changes.getStartPageToken
+func (f *fakeDrive) setStartPageToken(token string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.startPageToken = token
+}
+
+// addChanges publica uma página de mudanças lida a partir de pageToken
+func (f *fakeDrive) addChanges(pageToken string, page *drive.ChangeList) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.changePages[pageToken] = page
+}
+
+// channels retorna os IDs dos canais registrados e dos encerrados
+func (f *fakeDrive) channels() (watched, stopped []string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for _, channel := range f.watches {
+		watched = append(watched, channel.Id)
+	}
+	return watched, append([]string(nil), f.stopped...)
+}
+
+// requestCount retorna quantas vezes o método e caminho foram chamados
+func (f *fakeDrive) requestCount(methodAndPath string) int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.requests[methodAndPath]
+}
+
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(value)
+}
+
+// newTestDriveService cria um DriveService cujo cliente aponta para o Drive de teste
+func newTestDriveService(t *testing.T, server *httptest.Server) *DriveService {
+	srv, err := drive.NewService(context.Background(),
+		option.WithEndpoint(server.URL+"/"),
+		option.WithHTTPClient(server.Client()))
+	if err != nil {
+		t.Fatalf("drive.NewService: %v", err)
+	}
+
+	dir := t.TempDir()
+	return &DriveService{
+		folderID:       fakeDriveRootID,
+		tokenFilePath:  filepath.Join(dir, "token.json"), // inexistente: mantém o cliente de teste
+		renewedPath:    filepath.Join(dir, "drive_token.json"),
+		cacheTTL:       time.Hour,
+		catalogStore:   NewCatalogStore(filepath.Join(dir, "drive_catalog.json")),
+		refreshTrigger: make(chan struct{}, 1),
+		api:            srv,
+	}
+}
@@ -2,73 +2,143 @@ package service
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"log"
 	"net/http"
 	"os"
-	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/kollinn/casamento-mari-kollinn/internal/config"
 	"github.com/kollinn/casamento-mari-kollinn/internal/model"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
+	"golang.org/x/sync/singleflight"
 	"google.golang.org/api/drive/v3"
 	"google.golang.org/api/option"
 )
 
-// URLCache representa uma URL em cache com seu tempo de expiração
-type URLCache struct {
-	URL       string
-	ExpiresAt time.Time
-}
+// drivePageSize é o número máximo de arquivos por página aceito pela API do Drive
+const drivePageSize = 1000
+
+// driveFileFields são os campos de arquivo usados para montar o catálogo
+const driveFileFields = "id, name, mimeType, parents, trashed, webViewLink, thumbnailLink, description, properties, md5Checksum, modifiedTime, imageMediaMetadata(width, height, rotation)"
+
+// driveFolderMimeType identifica pastas no Google Drive
+const driveFolderMimeType = "application/vnd.google-apps.folder"
+
+// driveThumbnailLinkTTL é a idade máxima dos thumbnailLinks em cache. Os links
+// do Drive expiram em poucas horas e a API de mudanças só traz links novos
+// para os arquivos alterados, então a listagem completa é refeita antes disso.
+const driveThumbnailLinkTTL = time.Hour
 
 // DriveService gerencia a interação com o Google Drive
+// e implementa ImageSource
 type DriveService struct {
 	folderID      string
-	tokenFilePath string
+	tokenFilePath string // token.json gerado pelo setup_auth (CONFIG_DIR pode ser somente leitura)
+	renewedPath   string // cópia com os tokens renovados pelo servidor, em DATA_DIR
 	credFilePath  string
-	urlCache      map[string]URLCache
-	cacheMutex    sync.RWMutex
-	lastRequest   time.Time
-	requestMutex  sync.Mutex
-	initialized   bool
-	imageCache    []model.DriveImage
-	cacheLock     sync.RWMutex
+
+	initialized bool
+	imageCache  []model.DriveImage
+	cacheLock   sync.RWMutex
+
+	// Expiração e atualização em segundo plano da lista de imagens
+	cacheTTL         time.Duration
+	fetchedAt        time.Time
+	lastError        error
+	refreshMutex     sync.Mutex
+	refreshTrigger   chan struct{}
+	refresherRunning atomic.Bool
+	listeners        []func()
+
+	// Sincronização incremental pela API de mudanças (Changes)
+	syncInterval   time.Duration
+	startPageToken string
+	folders        map[string]string // ID da pasta -> nome do álbum
+	listedAt       time.Time         // última listagem completa (idade dos thumbnailLinks)
+
+	// Snapshot em disco da última lista obtida com sucesso
+	catalogStore *CatalogStore
+
+	// Agrupa cargas simultâneas do catálogo em uma única chamada ao Drive
+	loadGroup singleflight.Group
+
+	// Cliente autenticado compartilhado e estado da autenticação. credModTime
+	// é a data do arquivo de credencial usado para criar o cliente.
+	api         *drive.Service
+	tokenSource oauth2.TokenSource
+	credModTime time.Time
+	authErr     error
+	clientMutex sync.Mutex
+
+	// Chave da conta de serviço e usuário delegado; quando a chave está
+	// configurada, substitui o fluxo OAuth com credentials.json e token.json
+	serviceAccountFile string
+	delegateSubject    string
 }
 
 // NewDriveService cria uma nova instância do DriveService
-func NewDriveService() *DriveService {
-	folderID := os.Getenv("GOOGLE_DRIVE_FOLDER_ID")
+func NewDriveService(cfg *config.Config) *DriveService {
+	folderID := cfg.Drive.FolderID
 	if folderID == "" {
 		log.Printf("AVISO: GOOGLE_DRIVE_FOLDER_ID não configurado")
 		return nil
 	}
 
-	configDir := "./config"
-	tokenPath := filepath.Join(configDir, "token.json")
-	credPath := filepath.Join(configDir, "credentials.json")
+	log.Printf("Inicializando serviço do Drive com folder ID: %s (TTL do cache: %s)", folderID, cfg.Drive.CacheTTL)
+
+	ds := &DriveService{
+		folderID:       folderID,
+		tokenFilePath:  cfg.ConfigPath("token.json"),
+		renewedPath:    cfg.DataPath("drive_token.json"),
+		credFilePath:   cfg.ConfigPath("credentials.json"),
+		initialized:    false,
+		imageCache:     make([]model.DriveImage, 0),
+		cacheTTL:       cfg.Drive.CacheTTL,
+		syncInterval:   cfg.Drive.SyncInterval,
+		catalogStore:   NewCatalogStore(cfg.DataPath("drive_catalog.json")),
+		refreshTrigger: make(chan struct{}, 1),
+
+		serviceAccountFile: cfg.Drive.ServiceAccountFile,
+		delegateSubject:    cfg.Drive.Subject,
+	}
 
-	log.Printf("Inicializando serviço do Drive com folder ID: %s", folderID)
+	// Carrega o último catálogo salvo para servir a galeria imediatamente
+	ds.loadSnapshot()
 
-	return &DriveService{
-		folderID:      folderID,
-		tokenFilePath: tokenPath,
-		credFilePath:  credPath,
-		urlCache:      make(map[string]URLCache),
-		initialized:   false,
-		imageCache:    make([]model.DriveImage, 0),
-	}
+	return ds
 }
 
-// getClient retorna um cliente HTTP autenticado para o Google Drive
-func (ds *DriveService) getClient(ctx context.Context) (*http.Client, error) {
-	log.Printf("Obtendo cliente autenticado para Google Drive")
+// newTokenSource cria a fonte de tokens para o Google Drive. O token é
+// renovado automaticamente e cada renovação é gravada na cópia em DATA_DIR,
+// já que CONFIG_DIR pode ser somente leitura (Secret Files da Render).
+// Com uma conta de serviço configurada, os tokens são emitidos a partir da
+// chave e nada é gravado em disco.
+func (ds *DriveService) newTokenSource() (oauth2.TokenSource, error) {
+	log.Printf("Obtendo credenciais para Google Drive")
+
+	// O cliente vive mais que qualquer requisição, então não usa o contexto dela
+	ctx := context.Background()
+
+	if ds.serviceAccountFile != "" {
+		base, err := serviceAccountTokenSource(ctx, ds.serviceAccountFile, ds.delegateSubject)
+		if err != nil {
+			return nil, err
+		}
+		return oauth2.ReuseTokenSource(nil, &persistingTokenSource{
+			base:    base,
+			onError: ds.setAuthError,
+		}), nil
+	}
 
-	b, err := ioutil.ReadFile(ds.credFilePath)
+	b, err := os.ReadFile(ds.credFilePath)
 	if err != nil {
 		return nil, fmt.Errorf("não foi possível ler o arquivo de credenciais: %v", err)
 	}
@@ -78,140 +148,625 @@ func (ds *DriveService) getClient(ctx context.Context) (*http.Client, error) {
 		return nil, fmt.Errorf("não foi possível analisar o arquivo de credenciais: %v", err)
 	}
 
-	tokenBytes, err := ioutil.ReadFile(ds.tokenFilePath)
+	token, err := loadTokenFile(ds.tokenFile())
 	if err != nil {
-		return nil, fmt.Errorf("não foi possível ler o token: %v", err)
+		return nil, err
 	}
 
-	token := &oauth2.Token{}
-	err = json.Unmarshal(tokenBytes, token)
-	if err != nil {
-		return nil, fmt.Errorf("não foi possível analisar o token: %v", err)
+	return oauth2.ReuseTokenSource(token, &persistingTokenSource{
+		base:    config.TokenSource(ctx, token),
+		path:    ds.renewedPath,
+		onError: ds.setAuthError,
+		last:    token,
+	}), nil
+}
+
+// setAuthError registra o estado da autenticação após cada renovação de token
+func (ds *DriveService) setAuthError(err error) {
+	ds.clientMutex.Lock()
+	defer ds.clientMutex.Unlock()
+
+	if err != nil && !errors.Is(err, ErrTokenRevoked) && !errors.Is(err, ErrServiceAccountRejected) {
+		// Falhas transitórias (rede) não invalidam o cliente
+		log.Printf("Erro ao renovar token do Google Drive: %v", err)
+		return
+	}
+	if err != nil && ds.authErr == nil {
+		log.Printf("ERRO: %v", err)
 	}
+	ds.authErr = err
+}
 
-	return config.Client(ctx, token), nil
+// AuthError retorna o erro de autenticação atual, ou nil se o token é válido.
+// Retorna ErrTokenRevoked quando é preciso autorizar o aplicativo de novo e
+// ErrServiceAccountRejected quando a chave da conta de serviço foi recusada.
+func (ds *DriveService) AuthError() error {
+	ds.clientMutex.Lock()
+	defer ds.clientMutex.Unlock()
+	return ds.authErr
 }
 
-// GetDriveImages recupera imagens da pasta especificada do Google Drive
+// GetDriveImages recupera imagens da pasta especificada do Google Drive.
+// Depois da primeira carga, sempre responde do cache; quando o cache ou os
+// thumbnailLinks expiram, a lista antiga continua sendo servida enquanto uma
+// nova é buscada.
 func (ds *DriveService) GetDriveImages(ctx context.Context) ([]model.DriveImage, error) {
 	// Verifica se já temos as imagens em cache
 	ds.cacheLock.RLock()
-	if ds.initialized && len(ds.imageCache) > 0 {
-		log.Printf("Retornando %d imagens do cache", len(ds.imageCache))
+	if ds.initialized {
 		images := ds.imageCache
+		stale := time.Since(ds.fetchedAt) > ds.cacheTTL || time.Since(ds.listedAt) > driveThumbnailLinkTTL
 		ds.cacheLock.RUnlock()
+
+		if stale {
+			log.Printf("Cache de imagens expirado, agendando atualização")
+			ds.RequestRefresh()
+		}
+		log.Printf("Retornando %d imagens do cache", len(images))
 		return images, nil
 	}
 	ds.cacheLock.RUnlock()
 
-	// Não temos cache, precisamos buscar do Drive
+	// Não temos cache, precisamos buscar do Drive. Requisições simultâneas
+	// compartilham uma única listagem; o contexto da busca não depende do
+	// primeiro chamador, para que seu cancelamento não afete os demais.
+	_, err, shared := ds.loadGroup.Do("catalog", func() (interface{}, error) {
+		loadCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 60*time.Second)
+		defer cancel()
+		return nil, ds.Refresh(loadCtx)
+	})
+	if shared {
+		log.Printf("Carga do catálogo compartilhada entre requisições simultâneas")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ds.cacheLock.RLock()
+	defer ds.cacheLock.RUnlock()
+	return ds.imageCache, nil
+}
+
+// Refresh busca a lista completa de imagens no Drive e substitui o cache.
+// Em caso de erro, a lista anterior é mantida.
+func (ds *DriveService) Refresh(ctx context.Context) error {
+	ds.refreshMutex.Lock()
+	defer ds.refreshMutex.Unlock()
+
+	return ds.refreshLocked(ctx)
+}
+
+// refreshLocked faz a listagem completa. Deve ser chamado com refreshMutex travado.
+func (ds *DriveService) refreshLocked(ctx context.Context) error {
 	log.Printf("Buscando imagens da pasta %s no Google Drive", ds.folderID)
 
-	client, err := ds.getClient(ctx)
+	catalog, err := ds.fetchCatalog(ctx)
+	if err != nil {
+		ds.recordError(err)
+		return err
+	}
+
+	ds.storeCatalog(catalog)
+	log.Printf("Encontradas %d imagens no Google Drive", len(catalog.images))
+	return nil
+}
+
+// recordError guarda o erro da última atualização mantendo o cache atual
+func (ds *DriveService) recordError(err error) {
+	ds.cacheLock.Lock()
+	ds.lastError = err
+	cached := len(ds.imageCache)
+	initialized := ds.initialized
+	ds.cacheLock.Unlock()
+
+	if initialized {
+		log.Printf("Erro ao atualizar imagens, mantendo %d imagens do cache: %v", cached, err)
+	}
+}
+
+// storeCatalog substitui o cache de uma só vez, grava o snapshot em disco
+// e avisa os interessados
+func (ds *DriveService) storeCatalog(catalog *driveCatalog) {
+	fetchedAt := time.Now()
+
+	ds.cacheLock.Lock()
+	ds.imageCache = catalog.images
+	ds.folders = catalog.folders
+	ds.startPageToken = catalog.startPageToken
+	ds.listedAt = catalog.listedAt
+	ds.initialized = true
+	ds.fetchedAt = fetchedAt
+	ds.lastError = nil
+	listeners := ds.listeners
+	ds.cacheLock.Unlock()
+
+	ds.saveSnapshot()
+
+	for _, listener := range listeners {
+		listener()
+	}
+}
+
+// saveSnapshot grava o catálogo em cache no disco. Deve ser chamado com
+// refreshMutex travado, para que as gravações não se intercalem.
+func (ds *DriveService) saveSnapshot() {
+	ds.cacheLock.RLock()
+	snapshot := &CatalogSnapshot{
+		FolderID:       ds.folderID,
+		FetchedAt:      ds.fetchedAt,
+		Images:         ds.imageCache,
+		Folders:        ds.folders,
+		StartPageToken: ds.startPageToken,
+	}
+	ds.cacheLock.RUnlock()
+
+	if err := ds.catalogStore.Save(snapshot); err != nil {
+		log.Printf("Aviso: %v", err)
+	}
+}
+
+// Status implementa StatusReporter com o estado do catálogo e da última
+// comunicação com o Drive
+func (ds *DriveService) Status() SourceStatus {
+	ds.cacheLock.RLock()
+	status := SourceStatus{
+		Loaded:     ds.initialized,
+		ImageCount: len(ds.imageCache),
+	}
+	if !ds.fetchedAt.IsZero() {
+		fetchedAt := ds.fetchedAt
+		status.FetchedAt = &fetchedAt
+	}
+	if ds.lastError != nil {
+		status.LastError = ds.lastError.Error()
+	}
+	ds.cacheLock.RUnlock()
+
+	if err := ds.AuthError(); err != nil {
+		status.AuthError = err.Error()
+	}
+	return status
+}
+
+// Probe implementa Prober lendo só o ID da pasta raiz. Confirma que o Drive
+// responde e aceita as credenciais sem esperar a próxima atualização.
+func (ds *DriveService) Probe(ctx context.Context) error {
+	srv, err := ds.driveAPI(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := srv.Files.Get(ds.folderID).Fields("id").Context(ctx).Do(); err != nil {
+		return fmt.Errorf("erro ao consultar a pasta %s: %v", ds.folderID, err)
+	}
+	return nil
+}
+
+// loadSnapshot preenche o cache com o catálogo salvo em disco, se houver.
+// O catálogo é servido imediatamente, mas fica marcado como desatualizado:
+// a idade dos thumbnailLinks gravados não é conhecida (listedAt fica zerado),
+// então a primeira requisição agenda uma listagem completa que os renova.
+func (ds *DriveService) loadSnapshot() {
+	snapshot, err := ds.catalogStore.Load()
+	if err != nil {
+		log.Printf("Aviso: %v", err)
+		return
+	}
+	if snapshot == nil {
+		return
+	}
+	if snapshot.FolderID != ds.folderID {
+		log.Printf("Snapshot do catálogo é de outra pasta (%s), ignorando", snapshot.FolderID)
+		return
+	}
+
+	ds.cacheLock.Lock()
+	ds.imageCache = snapshot.Images
+	ds.folders = snapshot.Folders
+	ds.startPageToken = snapshot.StartPageToken
+	ds.fetchedAt = snapshot.FetchedAt
+	ds.initialized = true
+	ds.cacheLock.Unlock()
+
+	log.Printf("Carregadas %d imagens do snapshot de %s", len(snapshot.Images), snapshot.FetchedAt.Format(time.RFC3339))
+}
+
+// RequestRefresh agenda uma atualização do cache sem bloquear o chamador
+func (ds *DriveService) RequestRefresh() {
+	if ds.refresherRunning.Load() {
+		select {
+		case ds.refreshTrigger <- struct{}{}:
+		default:
+			// Já existe uma atualização pendente
+		}
+		return
+	}
+
+	// Sem atualizador em segundo plano, atualiza em uma goroutine avulsa
+	go ds.loadGroup.Do("sync", func() (interface{}, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+		return nil, ds.Sync(ctx)
+	})
+}
+
+// RunRefresher sincroniza o cache periodicamente e sempre que RequestRefresh
+// é chamado, até que o contexto seja cancelado
+func (ds *DriveService) RunRefresher(ctx context.Context) {
+	ds.refresherRunning.Store(true)
+	defer ds.refresherRunning.Store(false)
+
+	ticker := time.NewTicker(ds.syncInterval)
+	defer ticker.Stop()
+
+	log.Printf("Atualizador do cache de imagens iniciado (intervalo: %s)", ds.syncInterval)
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Printf("Atualizador do cache de imagens encerrado")
+			return
+		case <-ticker.C:
+		case <-ds.refreshTrigger:
+		}
+
+		refreshCtx, cancel := context.WithTimeout(ctx, 60*time.Second)
+		ds.Sync(refreshCtx)
+		cancel()
+	}
+}
+
+// OnRefresh registra uma função chamada sempre que a lista de imagens é atualizada
+func (ds *DriveService) OnRefresh(listener func()) {
+	ds.cacheLock.Lock()
+	defer ds.cacheLock.Unlock()
+	ds.listeners = append(ds.listeners, listener)
+}
+
+// driveCatalog é o resultado de uma listagem completa da pasta do Drive
+type driveCatalog struct {
+	images         []model.DriveImage
+	folders        map[string]string // ID da pasta -> nome do álbum ("" para a raiz)
+	startPageToken string
+	listedAt       time.Time
+}
+
+// driveAPI retorna o serviço autenticado da API do Drive. O cliente é criado
+// uma única vez e só é recriado quando o arquivo de credencial muda (por
+// exemplo, um token.json novo gerado pelo setup_auth). As renovações gravadas
+// pelo próprio servidor vão para outro arquivo e não recriam o cliente.
+func (ds *DriveService) driveAPI(ctx context.Context) (*drive.Service, error) {
+	ds.clientMutex.Lock()
+	defer ds.clientMutex.Unlock()
+
+	info, statErr := os.Stat(ds.credentialFile())
+	if ds.api != nil && (statErr != nil || info.ModTime().Equal(ds.credModTime)) {
+		return ds.api, nil
+	}
+	if statErr != nil {
+		return nil, fmt.Errorf("erro ao obter cliente: %v", statErr)
+	}
+
+	tokenSource, err := ds.newTokenSource()
 	if err != nil {
 		return nil, fmt.Errorf("erro ao obter cliente: %v", err)
 	}
 
-	srv, err := drive.NewService(ctx, option.WithHTTPClient(client))
+	// O cliente vive mais que qualquer requisição, então não usa o contexto dela
+	client := oauth2.NewClient(context.Background(), tokenSource)
+	srv, err := drive.NewService(context.Background(), option.WithHTTPClient(client))
 	if err != nil {
 		return nil, fmt.Errorf("erro ao criar serviço Drive: %v", err)
 	}
 
-	query := fmt.Sprintf("'%s' in parents and mimeType contains 'image/'", ds.folderID)
-	log.Printf("Executando query: %s", query)
+	if ds.api != nil {
+		log.Printf("Credencial do Google Drive alterada, cliente recriado")
+	}
+	ds.api = srv
+	ds.tokenSource = tokenSource
+	ds.credModTime = info.ModTime()
+	// Uma credencial nova ainda não foi recusada
+	ds.authErr = nil
+	return srv, nil
+}
+
+// credentialFile retorna o arquivo do qual o cliente é criado
+func (ds *DriveService) credentialFile() string {
+	if ds.serviceAccountFile != "" {
+		return ds.serviceAccountFile
+	}
+	return ds.tokenFilePath
+}
+
+// tokenFile retorna o token a carregar: a cópia renovada em DATA_DIR, a menos
+// que o token.json seja mais novo que ela (gerado de novo pelo setup_auth)
+func (ds *DriveService) tokenFile() string {
+	renewed, err := os.Stat(ds.renewedPath)
+	if err != nil {
+		return ds.tokenFilePath
+	}
+	if original, err := os.Stat(ds.tokenFilePath); err == nil && original.ModTime().After(renewed.ModTime()) {
+		return ds.tokenFilePath
+	}
+	return ds.renewedPath
+}
+
+// fetchCatalog lista todas as imagens da pasta e dos álbuns no Drive
+func (ds *DriveService) fetchCatalog(ctx context.Context) (*driveCatalog, error) {
+	srv, err := ds.driveAPI(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// O token é obtido antes da listagem para não perder mudanças feitas durante ela
+	listedAt := time.Now()
+	startPageToken, err := srv.Changes.GetStartPageToken().Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("erro ao obter token de mudanças: %v", err)
+	}
 
-	fileList, err := srv.Files.List().
-		Q(query).
-		Fields("files(id, name, mimeType, webViewLink, thumbnailLink, description, properties)").
-		PageSize(100).
-		OrderBy("name").
-		Do()
+	images, folders, err := ds.listAlbumTree(ctx, srv)
 	if err != nil {
-		return nil, fmt.Errorf("erro ao listar arquivos: %v", err)
+		return nil, err
+	}
+
+	return &driveCatalog{
+		images:         images,
+		folders:        folders,
+		startPageToken: startPageToken.StartPageToken,
+		listedAt:       listedAt,
+	}, nil
+}
+
+// listAlbumTree percorre a pasta raiz e suas subpastas (álbuns) em largura,
+// retornando as imagens de todas elas e o mapa de pastas visitadas.
+// Imagens da raiz ficam sem álbum.
+func (ds *DriveService) listAlbumTree(ctx context.Context, srv *drive.Service) ([]model.DriveImage, map[string]string, error) {
+	type pendingFolder struct {
+		id   string
+		name string
 	}
 
 	var images []model.DriveImage
-	for _, file := range fileList.Files {
-		// Tentar obter o texto alternativo das propriedades personalizadas
-		altText := ""
-
-		// Primeiro verifica se existe nas propriedades personalizadas
-		if file.Properties != nil {
-			if alt, ok := file.Properties["altText"]; ok && alt != "" {
-				altText = alt
-			}
+	queue := []pendingFolder{{id: ds.folderID}}
+	folders := map[string]string{ds.folderID: ""}
+
+	for len(queue) > 0 {
+		folder := queue[0]
+		queue = queue[1:]
+
+		query := fmt.Sprintf("'%s' in parents and trashed = false and (mimeType contains 'image/' or mimeType = '%s')", folder.id, driveFolderMimeType)
+		log.Printf("Executando query: %s", query)
+
+		// Percorre todas as páginas da listagem seguindo o nextPageToken
+		pageCount := 0
+		err := srv.Files.List().
+			Q(query).
+			Fields("nextPageToken, files("+driveFileFields+")").
+			PageSize(drivePageSize).
+			OrderBy("name").
+			Pages(ctx, func(fileList *drive.FileList) error {
+				pageCount++
+				for _, file := range fileList.Files {
+					if file.MimeType == driveFolderMimeType {
+						// Subpastas viram álbuns; subpastas aninhadas usam o caminho completo
+						if _, ok := folders[file.Id]; !ok {
+							name := albumName(folder.name, file.Name)
+							folders[file.Id] = name
+							queue = append(queue, pendingFolder{id: file.Id, name: name})
+						}
+						continue
+					}
+
+					image := driveFileToImage(file)
+					if folder.id != ds.folderID {
+						image.AlbumID = folder.id
+						image.Album = folder.name
+					}
+					images = append(images, image)
+				}
+				log.Printf("Página %d da pasta %q: %d arquivos (total até agora: %d)", pageCount, folder.name, len(fileList.Files), len(images))
+				return nil
+			})
+		if err != nil {
+			return nil, nil, fmt.Errorf("erro ao listar arquivos: %v", err)
 		}
+	}
+
+	sortImages(images)
+	return images, folders, nil
+}
+
+// albumName monta o nome de um álbum aninhado, ex: "Festa/Pista"
+func albumName(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "/" + name
+}
+
+// driveFileToImage converte um arquivo do Drive no modelo da galeria
+func driveFileToImage(file *drive.File) model.DriveImage {
+	// Tentar obter o texto alternativo das propriedades personalizadas
+	altText := ""
 
-		// Se não encontrou nas propriedades, usa a descrição como fallback
-		if altText == "" && file.Description != "" {
-			altText = file.Description
+	// Primeiro verifica se existe nas propriedades personalizadas
+	if file.Properties != nil {
+		if alt, ok := file.Properties["altText"]; ok && alt != "" {
+			altText = alt
 		}
+	}
 
-		// Se ainda estiver vazio, usa o nome do arquivo sem a extensão
-		if altText == "" {
-			altText = file.Name
-			// Remove a extensão do arquivo
-			if dotIndex := strings.LastIndex(altText, "."); dotIndex > 0 {
-				altText = altText[:dotIndex]
-			}
+	// Se não encontrou nas propriedades, usa a descrição como fallback
+	if altText == "" && file.Description != "" {
+		altText = file.Description
+	}
+
+	// Se ainda estiver vazio, usa o nome do arquivo sem a extensão
+	if altText == "" {
+		altText = file.Name
+		// Remove a extensão do arquivo
+		if dotIndex := strings.LastIndex(altText, "."); dotIndex > 0 {
+			altText = altText[:dotIndex]
 		}
+	}
 
-		images = append(images, model.DriveImage{
-			ID:            file.Id,
-			Name:          file.Name,
-			MimeType:      file.MimeType,
-			WebViewLink:   file.WebViewLink,
-			ThumbnailLink: file.ThumbnailLink,
-			AltText:       altText,
-		})
+	// A data de modificação vem no formato RFC 3339
+	modifiedTime, _ := time.Parse(time.RFC3339, file.ModifiedTime)
+
+	image := model.DriveImage{
+		ID:            file.Id,
+		Name:          file.Name,
+		MimeType:      file.MimeType,
+		WebViewLink:   file.WebViewLink,
+		ThumbnailLink: file.ThumbnailLink,
+		AltText:       altText,
+		Checksum:      file.Md5Checksum,
+		ModifiedTime:  modifiedTime,
 	}
 
-	// Atualiza o cache
-	ds.cacheLock.Lock()
-	ds.imageCache = images
-	ds.initialized = true
-	ds.cacheLock.Unlock()
+	// Fotos giradas em 90° ou 270° são exibidas com as dimensões trocadas
+	if metadata := file.ImageMediaMetadata; metadata != nil {
+		image.Width, image.Height = int(metadata.Width), int(metadata.Height)
+		if metadata.Rotation%2 == 1 {
+			image.Width, image.Height = image.Height, image.Width
+		}
+	}
+	return image
+}
 
-	log.Printf("Encontradas %d imagens no Google Drive", len(images))
-	return images, nil
+// ListImages implementa ImageSource retornando as imagens da pasta do Drive
+func (ds *DriveService) ListImages(ctx context.Context) ([]model.DriveImage, error) {
+	return ds.GetDriveImages(ctx)
 }
 
-// GenerateTemporaryAccessURL gera URLs temporárias para acesso às imagens
-func (ds *DriveService) GenerateTemporaryAccessURL(ctx context.Context, imageID string) (string, error) {
-	ds.cacheMutex.RLock()
-	if cached, ok := ds.urlCache[imageID]; ok {
-		if time.Now().Before(cached.ExpiresAt) {
-			ds.cacheMutex.RUnlock()
-			return cached.URL, nil
-		}
+// GetImage implementa ImageSource buscando a imagem na lista em cache
+func (ds *DriveService) GetImage(ctx context.Context, id string) (*model.DriveImage, error) {
+	images, err := ds.GetDriveImages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return findImage(images, id)
+}
+
+// OpenImage implementa ImageSource baixando o thumbnail da imagem do Drive,
+// ou o arquivo original quando size é ImageSizeOriginal
+func (ds *DriveService) OpenImage(ctx context.Context, id string, size string) (io.ReadCloser, string, error) {
+	image, err := ds.GetImage(ctx, id)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if size == ImageSizeOriginal {
+		return ds.downloadOriginal(ctx, image)
+	}
+
+	if image.ThumbnailLink == "" {
+		return nil, "", ErrImageNotFound
 	}
-	ds.cacheMutex.RUnlock()
+	return ds.fetchThumbnail(ctx, image.ThumbnailLink)
+}
 
-	client, err := ds.getClient(ctx)
+// OpenThumbnail implementa ThumbnailSource pedindo ao Drive a miniatura
+// com a largura informada, sem baixar o original
+func (ds *DriveService) OpenThumbnail(ctx context.Context, id string, width int) (io.ReadCloser, string, error) {
+	image, err := ds.GetImage(ctx, id)
 	if err != nil {
-		return "", fmt.Errorf("erro ao obter cliente: %v", err)
+		return nil, "", err
+	}
+	if image.ThumbnailLink == "" {
+		return nil, "", ErrImageNotFound
+	}
+	return ds.fetchThumbnail(ctx, driveThumbnailURL(image.ThumbnailLink, driveThumbnailSize(image, width)))
+}
+
+// driveThumbnailSize converte a largura desejada no maior lado da miniatura,
+// que é o que o parâmetro =sNNN do Drive limita
+func driveThumbnailSize(image *model.DriveImage, width int) int {
+	if image.Width > 0 && image.Height > image.Width {
+		return width * image.Height / image.Width
+	}
+	return width
+}
+
+// driveThumbnailURL troca as opções de tamanho do link de miniatura (o
+// sufixo "=s220" ou similar após a última "/") por "=s<size>". Links com
+// query string não seguem esse formato e são mantidos.
+func driveThumbnailURL(link string, size int) string {
+	if strings.Contains(link, "?") {
+		return link
 	}
+	if i := strings.LastIndex(link, "="); i > strings.LastIndex(link, "/") {
+		link = link[:i]
+	}
+	return link + "=s" + strconv.Itoa(size)
+}
+
+// fetchThumbnail baixa uma miniatura a partir do link fornecido pelo Drive
+func (ds *DriveService) fetchThumbnail(ctx context.Context, thumbnailLink string) (io.ReadCloser, string, error) {
+	log.Printf("Fazendo proxy para URL: %s", thumbnailLink)
 
-	srv, err := drive.NewService(ctx, option.WithHTTPClient(client))
+	// Configura um cliente HTTP com timeout
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", thumbnailLink, nil)
 	if err != nil {
-		return "", fmt.Errorf("erro ao criar serviço Drive: %v", err)
+		return nil, "", fmt.Errorf("erro ao criar requisição: %v", err)
 	}
 
-	file, err := srv.Files.Get(imageID).Fields("webContentLink").Do()
+	// Adiciona headers para evitar problemas de CORS
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) Chrome/100.0.1000.0")
+
+	resp, err := client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("erro ao obter arquivo: %v", err)
+		return nil, "", fmt.Errorf("erro ao buscar imagem: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusNotFound {
+			// Link expirado antes do previsto: força a listagem completa
+			ds.expireThumbnailLinks()
+		}
+		return nil, "", fmt.Errorf("erro do Google Drive: %s", resp.Status)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "image/jpeg"
 	}
 
-	if file.WebContentLink == "" {
-		return "", fmt.Errorf("link de conteúdo não disponível")
+	return resp.Body, contentType, nil
+}
+
+// expireThumbnailLinks marca os thumbnailLinks em cache como expirados e
+// agenda a listagem completa que os renova
+func (ds *DriveService) expireThumbnailLinks() {
+	ds.cacheLock.Lock()
+	expired := !ds.listedAt.IsZero()
+	ds.listedAt = time.Time{}
+	ds.cacheLock.Unlock()
+
+	if expired {
+		log.Printf("Link de miniatura do Drive recusado, agendando listagem completa")
+		ds.RequestRefresh()
 	}
+}
 
-	ds.cacheMutex.Lock()
-	ds.urlCache[imageID] = URLCache{
-		URL:       file.WebContentLink,
-		ExpiresAt: time.Now().Add(30 * time.Minute),
+// downloadOriginal baixa o arquivo original da imagem pela API do Drive
+func (ds *DriveService) downloadOriginal(ctx context.Context, image *model.DriveImage) (io.ReadCloser, string, error) {
+	srv, err := ds.driveAPI(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+
+	log.Printf("Baixando original da imagem %s do Drive", image.ID)
+
+	resp, err := srv.Files.Get(image.ID).Context(ctx).Download()
+	if err != nil {
+		return nil, "", fmt.Errorf("erro ao baixar imagem original: %v", err)
 	}
-	ds.cacheMutex.Unlock()
 
-	return file.WebContentLink, nil
+	return resp.Body, image.MimeType, nil
 }
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kollinn/casamento-mari-kollinn/internal/model"
+)
+
+func TestDriveThumbnailURL(t *testing.T) {
+	tests := []struct {
+		link string
+		size int
+		want string
+	}{
+		{"https://lh3.googleusercontent.com/drive-storage/AJQWtBN=s220", 640, "https://lh3.googleusercontent.com/drive-storage/AJQWtBN=s640"},
+		{"https://lh3.googleusercontent.com/drive-storage/AJQWtBN=w220-h180-p", 320, "https://lh3.googleusercontent.com/drive-storage/AJQWtBN=s320"},
+		{"https://lh3.googleusercontent.com/drive-storage/AJQWtBN", 480, "https://lh3.googleusercontent.com/drive-storage/AJQWtBN=s480"},
+		{"https://drive.google.com/thumbnail?id=abc", 320, "https://drive.google.com/thumbnail?id=abc"},
+	}
+	for _, tt := range tests {
+		if got := driveThumbnailURL(tt.link, tt.size); got != tt.want {
+			t.Errorf("driveThumbnailURL(%q, %d) = %q, esperava %q", tt.link, tt.size, got, tt.want)
+		}
+	}
+}
+
+func TestDriveThumbnailSize(t *testing.T) {
+	tests := []struct {
+		name          string
+		width, height int
+		want          int
+	}{
+		{"paisagem", 6000, 4000, 640},
+		{"retrato", 4000, 6000, 960},
+		{"dimensões desconhecidas", 0, 0, 640},
+	}
+	for _, tt := range tests {
+		image := &model.DriveImage{Width: tt.width, Height: tt.height}
+		if got := driveThumbnailSize(image, 640); got != tt.want {
+			t.Errorf("%s: driveThumbnailSize = %d, esperava %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDriveProbe(t *testing.T) {
+	fake, server := newFakeDrive(t)
+	ds := newTestDriveService(t, server)
+
+	if err := ds.Probe(context.Background()); err != nil {
+		t.Fatalf("Probe: %v", err)
+	}
+	if got := fake.requestCount("GET /files/" + fakeDriveRootID); got != 1 {
+		t.Errorf("Probe fez %d consultas à pasta raiz, esperava 1", got)
+	}
+	if got := fake.requestCount("GET /files"); got != 0 {
+		t.Errorf("Probe listou a pasta %d vezes; deveria só consultá-la", got)
+	}
+
+	ds.folderID = "pasta-removida"
+	if err := ds.Probe(context.Background()); err == nil {
+		t.Error("Probe de uma pasta inexistente deveria falhar")
+	}
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+)
+
+// Duração solicitada para cada canal de notificação do Drive e antecedência
+// com que ele é renovado antes de expirar
+const (
+	driveWatchDuration    = 24 * time.Hour
+	driveWatchRenewMargin = time.Hour
+)
+
+// driveWatchRetry é o intervalo mínimo entre registros do canal, usado também
+// para tentar de novo após uma falha
+var driveWatchRetry = time.Minute
+
+// RunWatcher registra um canal de notificações (changes.watch) que avisa o
+// endereço informado sempre que algo muda no Drive, renovando-o antes de
+// expirar, até que o contexto seja cancelado
+func (ds *DriveService) RunWatcher(ctx context.Context, address, token string) {
+	log.Printf("Registrando notificações do Drive para %s", address)
+
+	// Os canais são encerrados mesmo depois do cancelamento, para não deixar
+	// notificações órfãs
+	stop := func(channel *drive.Channel) {
+		stopCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+		defer cancel()
+		ds.stopChannel(stopCtx, channel)
+	}
+
+	var current *drive.Channel
+	defer func() {
+		if current != nil {
+			stop(current)
+		}
+	}()
+
+	for {
+		channel, err := ds.watchChanges(ctx, address, token)
+		retry := driveWatchRetry
+		if err != nil {
+			log.Printf("Erro ao registrar notificações do Drive: %v", err)
+		} else {
+			if current != nil {
+				stop(current)
+			}
+			current = channel
+			expiresAt := time.UnixMilli(channel.Expiration)
+			log.Printf("Canal de notificações do Drive %s ativo até %s", channel.Id, expiresAt.Format(time.RFC3339))
+			if renew := time.Until(expiresAt) - driveWatchRenewMargin; renew > retry {
+				retry = renew
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retry):
+		}
+	}
+}
+
+// watchChanges cria um novo canal de notificações a partir do token atual
+func (ds *DriveService) watchChanges(ctx context.Context, address, token string) (*drive.Channel, error) {
+	srv, err := ds.driveAPI(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	startPageToken, err := srv.Changes.GetStartPageToken().Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("erro ao obter token de mudanças: %v", err)
+	}
+
+	idBytes := make([]byte, 16)
+	if _, err := rand.Read(idBytes); err != nil {
+		return nil, fmt.Errorf("erro ao gerar ID do canal: %v", err)
+	}
+
+	channel, err := srv.Changes.Watch(startPageToken.StartPageToken, &drive.Channel{
+		Id:         "casamento-" + hex.EncodeToString(idBytes),
+		Type:       "web_hook",
+		Address:    address,
+		Token:      token,
+		Expiration: time.Now().Add(driveWatchDuration).UnixMilli(),
+	}).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("erro ao registrar canal: %v", err)
+	}
+	return channel, nil
+}
+
+// stopChannel encerra um canal de notificações que não é mais usado
+func (ds *DriveService) stopChannel(ctx context.Context, channel *drive.Channel) {
+	srv, err := ds.driveAPI(ctx)
+	if err == nil {
+		err = srv.Channels.Stop(&drive.Channel{Id: channel.Id, ResourceId: channel.ResourceId}).Context(ctx).Do()
+	}
+	if err != nil {
+		log.Printf("Aviso: erro ao encerrar canal de notificações %s: %v", channel.Id, err)
+	}
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatchChangesRegistersChannel(t *testing.T) {
+	fake, server := newFakeDrive(t)
+	fake.setStartPageToken("42")
+	ds := newTestDriveService(t, server)
+
+	channel, err := ds.watchChanges(context.Background(), "https://casamento.example/api/hooks/drive", "segredo")
+	if err != nil {
+		t.Fatalf("watchChanges: %v", err)
+	}
+
+	if len(fake.watches) != 1 {
+		t.Fatalf("esperava 1 changes.watch, obteve %d", len(fake.watches))
+	}
+	request := fake.watches[0]
+	if request.Type != "web_hook" || request.Address != "https://casamento.example/api/hooks/drive" || request.Token != "segredo" {
+		t.Errorf("canal registrado com tipo, endereço ou token errado: %+v", request)
+	}
+	if !strings.HasPrefix(request.Id, "casamento-") {
+		t.Errorf("ID do canal %q sem o prefixo casamento-", request.Id)
+	}
+	expiresIn := time.Until(time.UnixMilli(request.Expiration))
+	if expiresIn < driveWatchDuration-time.Minute || expiresIn > driveWatchDuration {
+		t.Errorf("expiração pedida em %s, esperava %s", expiresIn, driveWatchDuration)
+	}
+	if channel.Id != request.Id || channel.ResourceId == "" {
+		t.Errorf("canal retornado incompleto: %+v", channel)
+	}
+}
+
+func TestWatchChangesReportsRegistrationFailure(t *testing.T) {
+	fake, server := newFakeDrive(t)
+	fake.failWatches = 1
+	ds := newTestDriveService(t, server)
+
+	if _, err := ds.watchChanges(context.Background(), "https://casamento.example/api/hooks/drive", "segredo"); err == nil {
+		t.Fatal("esperava erro quando o Drive recusa o changes.watch")
+	}
+}
+
+func TestRunWatcherRenewsAndStopsChannels(t *testing.T) {
+	previous := driveWatchRetry
+	driveWatchRetry = 10 * time.Millisecond
+	t.Cleanup(func() { driveWatchRetry = previous })
+
+	fake, server := newFakeDrive(t)
+	// Canais que expiram logo são renovados no intervalo mínimo; a primeira
+	// tentativa falha para exercitar a repetição
+	fake.watchTTL = time.Millisecond
+	fake.failWatches = 1
+	ds := newTestDriveService(t, server)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		ds.RunWatcher(ctx, "https://casamento.example/api/hooks/drive", "segredo")
+		close(done)
+	}()
+
+	// Dois registros renovados (e encerrados) e um terceiro ativo
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if watched, stopped := fake.channels(); len(watched) >= 3 && len(stopped) >= 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			cancel()
+			t.Fatal("o canal não foi renovado a tempo")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	// Cada renovação encerra o canal anterior e o último é encerrado na saída.
+	// Um registro interrompido pelo cancelamento nunca chega ao RunWatcher.
+	watched, stopped := fake.channels()
+	if len(stopped) < 3 {
+		t.Fatalf("esperava ao menos 3 canais encerrados, obteve %v (registrados: %v)", stopped, watched)
+	}
+	for i, id := range stopped {
+		if watched[i] != id {
+			t.Fatalf("canais encerrados fora de ordem: registrados %v, encerrados %v", watched, stopped)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package service
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kollinn/casamento-mari-kollinn/internal/fileutil"
+)
+
+// versionTagLength é o tamanho do resumo da versão guardado no nome do arquivo
+const versionTagLength = 16
+
+// diskCacheEntry descreve um arquivo guardado no DiskImageCache. tag resume
+// a versão do original (checksum ou data de modificação) usada para gerá-lo.
+type diskCacheEntry struct {
+	key         string
+	tag         string
+	size        int64
+	contentType string
+}
+
+// fileName é o nome do arquivo da entrada: a chave seguida do resumo da versão
+func (e *diskCacheEntry) fileName() string {
+	if e.tag == "" {
+		return e.key
+	}
+	return e.key + "_" + e.tag
+}
+
+// DiskImageCache guarda em disco os bytes das imagens servidas pelo proxy,
+// com tamanho máximo e descarte das menos usadas (LRU). Cada arquivo guarda
+// a versão do original, e uma versão diferente conta como ausência: uma foto
+// substituída na fonte não continua sendo servida com os bytes antigos.
+type DiskImageCache struct {
+	dir      string
+	maxBytes int64
+	size     int64
+	entries  map[string]*list.Element // chave -> elemento da lista LRU
+	lru      *list.List               // frente = usado mais recentemente
+	mutex    sync.Mutex
+}
+
+// NewDiskImageCache cria o cache no diretório informado, reaproveitando os
+// arquivos já existentes (a ordem de uso é recuperada pela data de modificação)
+func NewDiskImageCache(dir string, maxBytes int64) (*DiskImageCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("erro ao criar diretório do cache de imagens: %v", err)
+	}
+
+	dc := &DiskImageCache{
+		dir:      dir,
+		maxBytes: maxBytes,
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+	}
+
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler diretório do cache de imagens: %v", err)
+	}
+
+	type existingFile struct {
+		entry   *diskCacheEntry
+		modTime time.Time
+	}
+	var files []existingFile
+	for _, dirEntry := range dirEntries {
+		key, tag, _ := strings.Cut(dirEntry.Name(), "_")
+		if dirEntry.IsDir() || len(key) != sha256.Size*2 || (tag != "" && len(tag) != versionTagLength) {
+			continue
+		}
+		info, err := dirEntry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, existingFile{
+			entry:   &diskCacheEntry{key: key, tag: tag, size: info.Size()},
+			modTime: info.ModTime(),
+		})
+	}
+
+	// Do mais antigo para o mais recente, para que o mais recente fique na
+	// frente; de duas versões da mesma imagem, fica a mais recente
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	for _, file := range files {
+		if element, ok := dc.entries[file.entry.key]; ok {
+			dc.removeElementLocked(element)
+		}
+		dc.entries[file.entry.key] = dc.lru.PushFront(file.entry)
+		dc.size += file.entry.size
+	}
+
+	dc.mutex.Lock()
+	dc.evictLocked()
+	dc.mutex.Unlock()
+
+	log.Printf("Cache de imagens em %s: %d arquivos, %d de %d bytes", dir, dc.lru.Len(), dc.size, maxBytes)
+	return dc, nil
+}
+
+// Get abre a imagem em cache para o ID e a variante de tamanho informados.
+// Com version, um arquivo gerado a partir de outra versão do original é
+// descartado; sem version (metadados indisponíveis), qualquer versão serve.
+// O chamador deve fechar o arquivo retornado.
+func (dc *DiskImageCache) Get(id, variant, version string) (*os.File, string, bool) {
+	key := diskCacheKey(id, variant)
+
+	dc.mutex.Lock()
+	element, ok := dc.entries[key]
+	if !ok {
+		dc.mutex.Unlock()
+		return nil, "", false
+	}
+	entry := element.Value.(*diskCacheEntry)
+	if version != "" && entry.tag != versionTag(version) {
+		log.Printf("Imagem %s (%s) em cache é de uma versão anterior, descartando", id, variant)
+		dc.removeElementLocked(element)
+		dc.mutex.Unlock()
+		return nil, "", false
+	}
+	dc.lru.MoveToFront(element)
+	contentType := entry.contentType
+	path := filepath.Join(dc.dir, entry.fileName())
+	dc.mutex.Unlock()
+
+	file, err := os.Open(path)
+	if err != nil {
+		dc.remove(entry)
+		return nil, "", false
+	}
+
+	// Arquivos recuperados na inicialização não têm o tipo guardado
+	if contentType == "" {
+		contentType = detectContentType(file)
+		dc.mutex.Lock()
+		entry.contentType = contentType
+		dc.mutex.Unlock()
+	}
+
+	// Guarda a ordem de uso no próprio arquivo para sobreviver a reinícios
+	now := time.Now()
+	os.Chtimes(path, now, now)
+
+	return file, contentType, true
+}
+
+// Put guarda os bytes da imagem gerados a partir da versão informada do
+// original, substituindo outras versões, e descarta as menos usadas se
+// necessário
+func (dc *DiskImageCache) Put(id, variant, version string, data []byte, contentType string) error {
+	size := int64(len(data))
+	if size > dc.maxBytes {
+		return nil
+	}
+
+	entry := &diskCacheEntry{key: diskCacheKey(id, variant), size: size, contentType: contentType}
+	if version != "" {
+		entry.tag = versionTag(version)
+	}
+	if err := fileutil.WriteFileAtomic(filepath.Join(dc.dir, entry.fileName()), data, 0644); err != nil {
+		return fmt.Errorf("erro ao gravar imagem no cache: %v", err)
+	}
+
+	dc.mutex.Lock()
+	defer dc.mutex.Unlock()
+
+	if element, ok := dc.entries[entry.key]; ok {
+		if element.Value.(*diskCacheEntry).fileName() == entry.fileName() {
+			dc.size -= element.Value.(*diskCacheEntry).size
+			dc.lru.Remove(element)
+		} else {
+			dc.removeElementLocked(element)
+		}
+	}
+	dc.entries[entry.key] = dc.lru.PushFront(entry)
+	dc.size += size
+
+	dc.evictLocked()
+	return nil
+}
+
+// remove descarta uma entrada do índice (o arquivo já não existe)
+func (dc *DiskImageCache) remove(entry *diskCacheEntry) {
+	dc.mutex.Lock()
+	defer dc.mutex.Unlock()
+
+	if element, ok := dc.entries[entry.key]; ok && element.Value == entry {
+		dc.size -= entry.size
+		dc.lru.Remove(element)
+		delete(dc.entries, entry.key)
+	}
+}
+
+// removeElementLocked apaga o arquivo e descarta a entrada do índice.
+// Deve ser chamado com dc.mutex travado.
+func (dc *DiskImageCache) removeElementLocked(element *list.Element) {
+	entry := element.Value.(*diskCacheEntry)
+	if err := os.Remove(filepath.Join(dc.dir, entry.fileName())); err != nil && !os.IsNotExist(err) {
+		log.Printf("Aviso: erro ao remover imagem do cache: %v", err)
+	}
+	dc.size -= entry.size
+	dc.lru.Remove(element)
+	delete(dc.entries, entry.key)
+}
+
+// evictLocked apaga as imagens menos usadas até caber no limite.
+// Deve ser chamado com dc.mutex travado.
+func (dc *DiskImageCache) evictLocked() {
+	for dc.size > dc.maxBytes {
+		element := dc.lru.Back()
+		if element == nil {
+			return
+		}
+		dc.removeElementLocked(element)
+	}
+}
+
+// diskCacheKey gera o nome do arquivo a partir do ID e da variante
+func diskCacheKey(id, variant string) string {
+	sum := sha256.Sum256([]byte(id + "\x00" + variant))
+	return hex.EncodeToString(sum[:])
+}
+
+// versionTag resume a versão do original para o nome do arquivo
+func versionTag(version string) string {
+	sum := sha256.Sum256([]byte(version))
+	return hex.EncodeToString(sum[:])[:versionTagLength]
+}
+
+// detectContentType identifica o tipo da imagem pelos primeiros bytes
+func detectContentType(file *os.File) string {
+	buffer := make([]byte, 512)
+	n, _ := io.ReadFull(file, buffer)
+	file.Seek(0, io.SeekStart)
+	return http.DetectContentType(buffer[:n])
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// cachedBytes lê a imagem do cache, ou retorna nil se ela não estiver lá
+func cachedBytes(t *testing.T, dc *DiskImageCache, id, version string) []byte {
+	t.Helper()
+	file, _, ok := dc.Get(id, "thumbnail", version)
+	if !ok {
+		return nil
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatalf("erro ao ler %s do cache: %v", id, err)
+	}
+	return data
+}
+
+// cacheFiles lista os arquivos no diretório do cache
+func cacheFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+func TestDiskImageCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	dir := t.TempDir()
+	dc, err := NewDiskImageCache(dir, 25)
+	if err != nil {
+		t.Fatalf("NewDiskImageCache: %v", err)
+	}
+
+	for _, id := range []string{"a", "b"} {
+		if err := dc.Put(id, "thumbnail", "v1", []byte("0123456789"), "image/jpeg"); err != nil {
+			t.Fatalf("Put(%s): %v", id, err)
+		}
+	}
+	// Usar "a" torna "b" a menos usada
+	if cachedBytes(t, dc, "a", "v1") == nil {
+		t.Fatal("a deveria estar no cache")
+	}
+	if err := dc.Put("c", "thumbnail", "v1", []byte("0123456789"), "image/jpeg"); err != nil {
+		t.Fatalf("Put(c): %v", err)
+	}
+
+	if cachedBytes(t, dc, "b", "v1") != nil {
+		t.Error("b deveria ter sido descartada")
+	}
+	for _, id := range []string{"a", "c"} {
+		if cachedBytes(t, dc, id, "v1") == nil {
+			t.Errorf("%s deveria continuar no cache", id)
+		}
+	}
+	if files := cacheFiles(t, dir); len(files) != 2 {
+		t.Errorf("arquivos no cache = %v, esperava 2", files)
+	}
+
+	// Uma imagem maior que o limite não é guardada nem descarta as outras
+	if err := dc.Put("d", "thumbnail", "v1", make([]byte, 30), "image/jpeg"); err != nil {
+		t.Fatalf("Put(d): %v", err)
+	}
+	if cachedBytes(t, dc, "d", "v1") != nil || cachedBytes(t, dc, "a", "v1") == nil {
+		t.Error("imagem maior que o limite alterou o cache")
+	}
+}
+
+func TestDiskImageCacheVersionTag(t *testing.T) {
+	dir := t.TempDir()
+	dc, err := NewDiskImageCache(dir, 1<<20)
+	if err != nil {
+		t.Fatalf("NewDiskImageCache: %v", err)
+	}
+
+	if err := dc.Put("foto", "thumbnail", "etag-1", []byte("versão 1"), "image/jpeg"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if got := cachedBytes(t, dc, "foto", "etag-1"); string(got) != "versão 1" {
+		t.Errorf("mesma versão: %q", got)
+	}
+	// Sem metadados da fonte, qualquer versão serve
+	if got := cachedBytes(t, dc, "foto", ""); string(got) != "versão 1" {
+		t.Errorf("versão desconhecida: %q", got)
+	}
+
+	// A versão nova substitui o arquivo da anterior
+	if err := dc.Put("foto", "thumbnail", "etag-2", []byte("versão 2"), "image/jpeg"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if files := cacheFiles(t, dir); len(files) != 1 {
+		t.Errorf("arquivos depois de trocar a versão = %v, esperava 1", files)
+	}
+	if got := cachedBytes(t, dc, "foto", "etag-2"); string(got) != "versão 2" {
+		t.Errorf("versão nova: %q", got)
+	}
+
+	// Com a foto substituída na fonte, a versão guardada é descartada
+	if got := cachedBytes(t, dc, "foto", "etag-3"); got != nil {
+		t.Errorf("versão diferente foi servida: %q", got)
+	}
+	if files := cacheFiles(t, dir); len(files) != 0 {
+		t.Errorf("arquivo da versão anterior não foi apagado: %v", files)
+	}
+}
+
+func TestDiskImageCacheRecoversFilesOnStartup(t *testing.T) {
+	dir := t.TempDir()
+	dc, err := NewDiskImageCache(dir, 1<<20)
+	if err != nil {
+		t.Fatalf("NewDiskImageCache: %v", err)
+	}
+
+	png := testImage(t, 4, 4)
+	base := time.Now().Add(-time.Hour)
+	for i, id := range []string{"antiga", "media", "recente"} {
+		if err := dc.Put(id, "thumbnail", "v1", png, "image/png"); err != nil {
+			t.Fatalf("Put(%s): %v", id, err)
+		}
+		// A ordem de uso fica na data de modificação dos arquivos
+		modTime := base.Add(time.Duration(i) * time.Minute)
+		entry := &diskCacheEntry{key: diskCacheKey(id, "thumbnail"), tag: versionTag("v1")}
+		if err := os.Chtimes(filepath.Join(dir, entry.fileName()), modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Arquivos que não seguem o formato de nome do cache são ignorados
+	for _, name := range []string{"notas.txt", diskCacheKey("x", "y") + "_curto"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Reiniciado com espaço para duas imagens, descarta a usada há mais tempo
+	restarted, err := NewDiskImageCache(dir, int64(2*len(png)))
+	if err != nil {
+		t.Fatalf("NewDiskImageCache ao reiniciar: %v", err)
+	}
+	if cachedBytes(t, restarted, "antiga", "v1") != nil {
+		t.Error("a imagem menos usada deveria ter sido descartada no reinício")
+	}
+	file, contentType, ok := restarted.Get("recente", "thumbnail", "v1")
+	if !ok {
+		t.Fatal("a imagem mais recente deveria ter sido recuperada")
+	}
+	file.Close()
+	// O tipo não é guardado em disco e é detectado pelo conteúdo
+	if contentType != "image/png" {
+		t.Errorf("tipo recuperado = %q, esperava image/png", contentType)
+	}
+	if cachedBytes(t, restarted, "media", "v1") == nil {
+		t.Error("a imagem intermediária deveria ter sido recuperada")
+	}
+	if restarted.size != int64(2*len(png)) {
+		t.Errorf("tamanho recuperado = %d, esperava %d", restarted.size, 2*len(png))
+	}
+}
@@ -0,0 +1,25 @@
+//go:build cgo
+
+package service
+
+import (
+	"image"
+	"io"
+
+	"github.com/bep/gowebp/libwebp"
+	"github.com/bep/gowebp/libwebp/webpoptions"
+)
+
+// webpQuality é a qualidade usada nas variantes WebP
+const webpQuality = 80
+
+// O codificador WebP usa a libwebp via cgo; binários compilados sem cgo
+// servem apenas JPEG
+func init() {
+	imageEncoders[ImageFormatWebP] = imageEncoder{"webp", func(w io.Writer, img image.Image) error {
+		return libwebp.Encode(w, img, webpoptions.EncodingOptions{
+			Quality:        webpQuality,
+			EncodingPreset: webpoptions.EncodingPresetPhoto,
+		})
+	}}
+}
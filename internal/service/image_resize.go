@@ -0,0 +1,178 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"strconv"
+	"strings"
+
+	// Decodificadores adicionais para as imagens originais
+	_ "image/gif"
+	_ "image/png"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// ImageWidths são as larguras geradas para as variantes redimensionadas, em
+// ordem crescente: as do srcset da galeria (320 a 960) e do modal (800 a
+// 2400). Fixá-las limita as variantes em cache por imagem.
+var ImageWidths = []int{320, 480, 640, 800, 960, 1200, 1600, 2400}
+
+// SnapImageWidth arredonda a largura pedida para a menor largura de
+// ImageWidths que a cobre, ou para a maior de todas
+func SnapImageWidth(width int) int {
+	for _, candidate := range ImageWidths {
+		if candidate >= width {
+			return candidate
+		}
+	}
+	return ImageWidths[len(ImageWidths)-1]
+}
+
+// Formatos das variantes redimensionadas. JPEG é sempre gerado; os demais
+// dependem de um codificador registrado (ver image_encode_webp.go).
+const (
+	ImageFormatJPEG = "image/jpeg"
+	ImageFormatWebP = "image/webp"
+	ImageFormatAVIF = "image/avif"
+)
+
+// jpegQuality é a qualidade usada nas variantes JPEG
+const jpegQuality = 82
+
+// imageEncoder grava uma imagem em um formato de saída
+type imageEncoder struct {
+	extension string
+	encode    func(w io.Writer, img image.Image) error
+}
+
+// imageEncoders são os formatos de saída disponíveis neste binário
+var imageEncoders = map[string]imageEncoder{
+	ImageFormatJPEG: {"jpg", func(w io.Writer, img image.Image) error {
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: jpegQuality})
+	}},
+}
+
+// preferredImageFormats são os formatos mais compactos que o JPEG, na ordem
+// de preferência quando o navegador aceita mais de um com o mesmo peso
+var preferredImageFormats = []string{ImageFormatAVIF, ImageFormatWebP}
+
+// maxDecodePixels limita o tamanho dos originais decodificados: uma imagem
+// de 40 megapixels ocupa até 160 MB em memória depois de decodificada
+const maxDecodePixels = 40_000_000
+
+// maxConcurrentResizes limita quantas imagens são decodificadas ao mesmo
+// tempo, para que a galeria inteira pedindo miniaturas não esgote a memória
+const maxConcurrentResizes = 2
+
+// ErrImageTooLarge indica que o original passa do limite de pixels
+var ErrImageTooLarge = errors.New("imagem grande demais para redimensionar")
+
+// resizeSlots é o semáforo das decodificações em andamento
+var resizeSlots = make(chan struct{}, maxConcurrentResizes)
+
+// NegotiateImageFormat escolhe o formato da variante a partir do header
+// Accept. Só formatos com codificador disponível e citados explicitamente
+// (image/* e */* não contam) substituem o JPEG, que todo navegador aceita.
+func NegotiateImageFormat(accept string) string {
+	weights := parseAccept(accept)
+
+	best := ImageFormatJPEG
+	bestWeight := 0.0
+	for _, format := range preferredImageFormats {
+		if _, ok := imageEncoders[format]; !ok {
+			continue
+		}
+		if weight := weights[format]; weight > bestWeight {
+			best, bestWeight = format, weight
+		}
+	}
+	return best
+}
+
+// parseAccept converte o header Accept em um mapa tipo -> peso (q=)
+func parseAccept(accept string) map[string]float64 {
+	weights := make(map[string]float64)
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(part, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(fields[0]))
+		if mediaType == "" {
+			continue
+		}
+
+		weight := 1.0
+		for _, param := range fields[1:] {
+			if value, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				if q, err := strconv.ParseFloat(value, 64); err == nil {
+					weight = q
+				}
+			}
+		}
+		weights[mediaType] = weight
+	}
+	return weights
+}
+
+// ResizeImage decodifica a imagem original e gera uma variante no formato
+// informado com a largura pedida (sem ampliar). Espera uma vaga no semáforo
+// enquanto o contexto permitir.
+func ResizeImage(ctx context.Context, original []byte, width int, format string) ([]byte, error) {
+	encoder, ok := imageEncoders[format]
+	if !ok {
+		return nil, fmt.Errorf("formato de imagem não suportado: %s", format)
+	}
+
+	// Confere as dimensões pelo cabeçalho antes de alocar a imagem inteira
+	config, _, err := image.DecodeConfig(bytes.NewReader(original))
+	if err != nil {
+		return nil, fmt.Errorf("erro ao decodificar imagem: %v", err)
+	}
+	if config.Width <= 0 || config.Height <= 0 {
+		return nil, fmt.Errorf("erro ao decodificar imagem: dimensões inválidas %dx%d", config.Width, config.Height)
+	}
+	if int64(config.Width)*int64(config.Height) > maxDecodePixels {
+		return nil, fmt.Errorf("%w: %dx%d", ErrImageTooLarge, config.Width, config.Height)
+	}
+
+	select {
+	case resizeSlots <- struct{}{}:
+		defer func() { <-resizeSlots }()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(original))
+	if err != nil {
+		return nil, fmt.Errorf("erro ao decodificar imagem: %v", err)
+	}
+
+	bounds := src.Bounds()
+	if width > bounds.Dx() {
+		width = bounds.Dx()
+	}
+	height := bounds.Dy() * width / bounds.Dx()
+	if height < 1 {
+		height = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Src, nil)
+
+	var buffer bytes.Buffer
+	if err := encoder.encode(&buffer, dst); err != nil {
+		return nil, fmt.Errorf("erro ao codificar imagem: %v", err)
+	}
+	return buffer.Bytes(), nil
+}
+
+// ImageVariantName identifica uma variante redimensionada pela largura e
+// pelo formato, ex: "w320.jpg" ou "w320.webp"
+func ImageVariantName(width int, format string) string {
+	return "w" + strconv.Itoa(width) + "." + imageEncoders[format].extension
+}
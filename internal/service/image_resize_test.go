@@ -0,0 +1,130 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// testImage gera um PNG com as dimensões informadas
+func testImage(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 128, 255})
+		}
+	}
+	var buffer bytes.Buffer
+	if err := png.Encode(&buffer, img); err != nil {
+		t.Fatalf("png.Encode: %v", err)
+	}
+	return buffer.Bytes()
+}
+
+func TestNegotiateImageFormat(t *testing.T) {
+	_, hasWebP := imageEncoders[ImageFormatWebP]
+
+	tests := []struct {
+		name   string
+		accept string
+		want   string
+	}{
+		{"sem Accept", "", ImageFormatJPEG},
+		{"apenas curingas", "image/*,*/*;q=0.8", ImageFormatJPEG},
+		{"Chrome", "image/avif,image/webp,image/apng,image/svg+xml,image/*,*/*;q=0.8", ImageFormatWebP},
+		{"WebP recusado", "image/webp;q=0,image/*", ImageFormatJPEG},
+		{"Safari antigo", "image/png,image/svg+xml,image/*;q=0.8,video/*;q=0.8,*/*;q=0.5", ImageFormatJPEG},
+		{"maiúsculas", "IMAGE/WEBP", ImageFormatWebP},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := tt.want
+			if want == ImageFormatWebP && !hasWebP {
+				want = ImageFormatJPEG
+			}
+			if got := NegotiateImageFormat(tt.accept); got != want {
+				t.Errorf("NegotiateImageFormat(%q) = %q, esperava %q", tt.accept, got, want)
+			}
+		})
+	}
+}
+
+func TestResizeImageJPEG(t *testing.T) {
+	data, err := ResizeImage(context.Background(), testImage(t, 400, 200), 100, ImageFormatJPEG)
+	if err != nil {
+		t.Fatalf("ResizeImage: %v", err)
+	}
+	config, err := jpeg.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("variante não é JPEG: %v", err)
+	}
+	if config.Width != 100 || config.Height != 50 {
+		t.Errorf("variante com %dx%d, esperava 100x50", config.Width, config.Height)
+	}
+}
+
+func TestResizeImageWebP(t *testing.T) {
+	if _, ok := imageEncoders[ImageFormatWebP]; !ok {
+		t.Skip("compilado sem cgo: codificador WebP indisponível")
+	}
+
+	data, err := ResizeImage(context.Background(), testImage(t, 400, 200), 100, ImageFormatWebP)
+	if err != nil {
+		t.Fatalf("ResizeImage: %v", err)
+	}
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || format != "webp" {
+		t.Fatalf("variante não é WebP (%q): %v", format, err)
+	}
+	if config.Width != 100 || config.Height != 50 {
+		t.Errorf("variante com %dx%d, esperava 100x50", config.Width, config.Height)
+	}
+	if ImageVariantName(100, ImageFormatWebP) != "w100.webp" {
+		t.Errorf("nome da variante WebP: %q", ImageVariantName(100, ImageFormatWebP))
+	}
+}
+
+func TestResizeImageDoesNotUpscale(t *testing.T) {
+	data, err := ResizeImage(context.Background(), testImage(t, 80, 40), 320, ImageFormatJPEG)
+	if err != nil {
+		t.Fatalf("ResizeImage: %v", err)
+	}
+	config, _ := jpeg.DecodeConfig(bytes.NewReader(data))
+	if config.Width != 80 {
+		t.Errorf("variante ampliada para %d pixels de largura", config.Width)
+	}
+}
+
+func TestResizeImageRejectsUnsupportedFormat(t *testing.T) {
+	if _, err := ResizeImage(context.Background(), testImage(t, 10, 10), 5, ImageFormatAVIF); err == nil {
+		t.Error("esperava erro para um formato sem codificador")
+	}
+	if _, err := ResizeImage(context.Background(), []byte("não é imagem"), 5, ImageFormatJPEG); err == nil || errors.Is(err, ErrImageTooLarge) {
+		t.Errorf("esperava erro de decodificação, obteve %v", err)
+	}
+}
+
+func TestSnapImageWidth(t *testing.T) {
+	tests := []struct{ width, want int }{
+		{1, 320},
+		{320, 320},
+		{321, 480},
+		{700, 800},
+		{960, 960},
+		{1000, 1200},
+		{2400, 2400},
+		{4096, 2400},
+	}
+	for _, tt := range tests {
+		if got := SnapImageWidth(tt.width); got != tt.want {
+			t.Errorf("SnapImageWidth(%d) = %d, esperava %d", tt.width, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/kollinn/casamento-mari-kollinn/internal/model"
+)
+
+// Tamanhos de imagem aceitos por ImageSource.OpenImage
+const (
+	ImageSizeThumbnail = ""
+	ImageSizeOriginal  = "original"
+)
+
+// ErrImageNotFound indica que a imagem solicitada não existe na fonte
+var ErrImageNotFound = errors.New("imagem não encontrada")
+
+// ImageSource abstrai o backend onde as fotos da galeria estão armazenadas
+type ImageSource interface {
+	// ListImages retorna todas as imagens disponíveis na fonte
+	ListImages(ctx context.Context) ([]model.DriveImage, error)
+
+	// GetImage retorna os metadados de uma imagem específica
+	GetImage(ctx context.Context, id string) (*model.DriveImage, error)
+
+	// OpenImage abre o conteúdo da imagem no tamanho solicitado e retorna
+	// também o Content-Type correspondente. O chamador deve fechar o reader.
+	OpenImage(ctx context.Context, id string, size string) (io.ReadCloser, string, error)
+}
+
+// ThumbnailMaxWidth é a maior largura pedida a uma ThumbnailSource. Acima
+// dela, as variantes são geradas a partir do original.
+const ThumbnailMaxWidth = 1000
+
+// ThumbnailSource é implementado por fontes que geram miniaturas no tamanho
+// pedido, evitando baixar e decodificar o original
+type ThumbnailSource interface {
+	// OpenThumbnail abre uma miniatura com a largura informada (ou menor,
+	// se o original for menor)
+	OpenThumbnail(ctx context.Context, id string, width int) (io.ReadCloser, string, error)
+}
+
+// Refresher é implementado por fontes que mantêm a lista de imagens em
+// cache e permitem forçar uma nova busca
+type Refresher interface {
+	Refresh(ctx context.Context) error
+}
+
+// BackgroundRefresher é implementado por fontes que podem agendar uma
+// atualização do cache sem bloquear o chamador
+type BackgroundRefresher interface {
+	RequestRefresh()
+}
+
+// Prober é implementado por fontes que conseguem verificar com uma chamada
+// barata se o backend está respondendo, sem recarregar o catálogo
+type Prober interface {
+	Probe(ctx context.Context) error
+}
+
+// SourceStatus descreve o estado de uma fonte de imagens, usado pelo /readyz
+type SourceStatus struct {
+	Loaded     bool       `json:"loaded"`
+	ImageCount int        `json:"imageCount"`
+	FetchedAt  *time.Time `json:"fetchedAt,omitempty"`
+	LastError  string     `json:"lastError,omitempty"`
+	AuthError  string     `json:"authError,omitempty"`
+}
+
+// StatusReporter é implementado por fontes que conhecem o próprio estado
+// sem precisar consultar o backend
+type StatusReporter interface {
+	Status() SourceStatus
+}
+
+// findImage procura uma imagem pelo ID em uma lista
+func findImage(images []model.DriveImage, id string) (*model.DriveImage, error) {
+	for i := range images {
+		if images[i].ID == id {
+			image := images[i]
+			return &image, nil
+		}
+	}
+	return nil, ErrImageNotFound
+}
+
+// sortImages ordena as imagens por álbum e depois por nome
+func sortImages(images []model.DriveImage) {
+	sort.SliceStable(images, func(i, j int) bool {
+		if images[i].Album != images[j].Album {
+			return images[i].Album < images[j].Album
+		}
+		return images[i].Name < images[j].Name
+	})
+}
+
+// albumID gera um ID estável e seguro para URLs a partir do caminho do álbum
+func albumID(album string) string {
+	sum := sha256.Sum256([]byte(album))
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// GroupAlbums agrupa as imagens por álbum, na ordem em que aparecem.
+// Imagens sem álbum (pasta raiz) não geram um álbum.
+func GroupAlbums(images []model.DriveImage) []model.Album {
+	var albums []model.Album
+	index := make(map[string]int)
+	for _, image := range images {
+		if image.AlbumID == "" {
+			continue
+		}
+		i, ok := index[image.AlbumID]
+		if !ok {
+			i = len(albums)
+			index[image.AlbumID] = i
+			albums = append(albums, model.Album{ID: image.AlbumID, Name: image.Album})
+		}
+		albums[i].ImageCount++
+	}
+	return albums
+}
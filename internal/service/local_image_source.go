@@ -0,0 +1,239 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kollinn/casamento-mari-kollinn/internal/model"
+	"golang.org/x/sync/singleflight"
+)
+
+// localListTTL é o tempo que a listagem do diretório fica em cache. Fotos
+// copiadas para o diretório aparecem depois desse intervalo ou de um Refresh.
+const localListTTL = time.Minute
+
+// localThumbnailWidth é a largura das miniaturas geradas a partir dos
+// arquivos locais quando OpenImage recebe ImageSizeThumbnail
+const localThumbnailWidth = 480
+
+// localImageExtensions lista as extensões reconhecidas como imagens
+var localImageExtensions = map[string]string{
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+	".avif": "image/avif",
+}
+
+// LocalImageSource implementa ImageSource servindo fotos de um diretório local
+type LocalImageSource struct {
+	dir        string
+	imageCache []model.DriveImage
+	paths      map[string]string // ID -> caminho do arquivo
+	fetchedAt  time.Time
+	cacheLock  sync.RWMutex
+	listGroup  singleflight.Group
+}
+
+// NewLocalImageSource cria uma fonte de imagens a partir de um diretório local
+func NewLocalImageSource(dir string) *LocalImageSource {
+	log.Printf("Inicializando galeria local no diretório: %s", dir)
+
+	return &LocalImageSource{dir: dir}
+}
+
+// ListImages implementa ImageSource listando as imagens do diretório.
+// Subdiretórios viram álbuns.
+func (ls *LocalImageSource) ListImages(ctx context.Context) ([]model.DriveImage, error) {
+	ls.cacheLock.RLock()
+	if ls.imageCache != nil && time.Since(ls.fetchedAt) < localListTTL {
+		images := ls.imageCache
+		ls.cacheLock.RUnlock()
+		return images, nil
+	}
+	ls.cacheLock.RUnlock()
+
+	// Requisições simultâneas compartilham uma única leitura do diretório
+	result, err, _ := ls.listGroup.Do("list", func() (interface{}, error) {
+		return ls.listAll(context.WithoutCancel(ctx))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.([]model.DriveImage), nil
+}
+
+// listAll percorre o diretório e atualiza o cache. Os arquivos não são
+// lidos: a versão de cada imagem vem do tamanho e da data de modificação.
+func (ls *LocalImageSource) listAll(ctx context.Context) ([]model.DriveImage, error) {
+	images := []model.DriveImage{}
+	paths := make(map[string]string)
+	err := filepath.WalkDir(ls.dir, func(path string, dirEntry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if dirEntry.IsDir() {
+			// Ignora diretórios ocultos (.git, .cache, ...)
+			if path != ls.dir && strings.HasPrefix(dirEntry.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		mimeType, ok := localImageExtensions[strings.ToLower(filepath.Ext(dirEntry.Name()))]
+		if !ok {
+			return nil
+		}
+
+		info, err := dirEntry.Info()
+		if err != nil {
+			log.Printf("Aviso: ignorando arquivo %s: %v", path, err)
+			return nil
+		}
+		rel, err := filepath.Rel(ls.dir, path)
+		if err != nil {
+			return nil
+		}
+
+		image := ls.imageFromFile(filepath.ToSlash(rel), path, mimeType, info)
+		paths[image.ID] = path
+		images = append(images, image)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler diretório da galeria: %v", err)
+	}
+
+	sortImages(images)
+
+	ls.cacheLock.Lock()
+	ls.imageCache = images
+	ls.paths = paths
+	ls.fetchedAt = time.Now()
+	ls.cacheLock.Unlock()
+
+	log.Printf("Encontradas %d imagens no diretório local", len(images))
+	return images, nil
+}
+
+// Refresh implementa Refresher descartando a listagem em cache
+func (ls *LocalImageSource) Refresh(ctx context.Context) error {
+	ls.cacheLock.Lock()
+	ls.fetchedAt = time.Time{}
+	ls.cacheLock.Unlock()
+
+	_, err := ls.ListImages(ctx)
+	return err
+}
+
+// GetImage implementa ImageSource
+func (ls *LocalImageSource) GetImage(ctx context.Context, id string) (*model.DriveImage, error) {
+	images, err := ls.ListImages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return findImage(images, id)
+}
+
+// OpenImage implementa ImageSource abrindo o arquivo original ou, para
+// ImageSizeThumbnail, uma miniatura JPEG gerada a partir dele. Só arquivos
+// presentes na listagem podem ser abertos.
+func (ls *LocalImageSource) OpenImage(ctx context.Context, id string, size string) (io.ReadCloser, string, error) {
+	image, err := ls.GetImage(ctx, id)
+	if err != nil {
+		return nil, "", err
+	}
+	ls.cacheLock.RLock()
+	path, ok := ls.paths[id]
+	ls.cacheLock.RUnlock()
+	if !ok {
+		return nil, "", ErrImageNotFound
+	}
+
+	if size != ImageSizeOriginal {
+		if thumbnail, ok := ls.thumbnail(ctx, path); ok {
+			return io.NopCloser(bytes.NewReader(thumbnail)), ImageFormatJPEG, nil
+		}
+	}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, "", ErrImageNotFound
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("erro ao abrir imagem: %v", err)
+	}
+	return file, image.MimeType, nil
+}
+
+// thumbnail gera a miniatura do arquivo. Formatos que não podem ser
+// decodificados aqui (AVIF) ou grandes demais são servidos no original.
+func (ls *LocalImageSource) thumbnail(ctx context.Context, path string) ([]byte, bool) {
+	original, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	thumbnail, err := ResizeImage(ctx, original, localThumbnailWidth, ImageFormatJPEG)
+	if err != nil {
+		log.Printf("Aviso: miniatura de %s indisponível, servindo o original: %v", path, err)
+		return nil, false
+	}
+	return thumbnail, true
+}
+
+// imageFromFile monta o modelo da galeria para um arquivo local. O ID é o
+// caminho relativo ao diretório, estável enquanto o arquivo não é movido.
+func (ls *LocalImageSource) imageFromFile(rel, path, mimeType string, info fs.FileInfo) model.DriveImage {
+	id := localImageID(rel)
+	image := model.DriveImage{
+		ID:            id,
+		Name:          info.Name(),
+		MimeType:      mimeType,
+		WebViewLink:   "/api/images/" + id + "/proxy?size=large",
+		ThumbnailLink: "/api/images/" + id + "/proxy",
+		AltText:       readSidecarAltText(path),
+		Checksum:      strconv.FormatInt(info.Size(), 36) + "-" + strconv.FormatInt(info.ModTime().UnixNano(), 36),
+		ModifiedTime:  info.ModTime(),
+	}
+	if album := filepath.ToSlash(filepath.Dir(filepath.FromSlash(rel))); album != "." {
+		image.Album = album
+		image.AlbumID = albumID(image.Album)
+	}
+	return image
+}
+
+// localImageID gera um ID seguro para URLs a partir do caminho relativo
+func localImageID(rel string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(rel))
+}
+
+// readSidecarAltText lê o texto alternativo de um arquivo .txt ao lado da imagem
+// (foto.jpg.txt ou foto.txt). Sem arquivo, usa o nome da imagem sem extensão.
+func readSidecarAltText(path string) string {
+	base := strings.TrimSuffix(path, filepath.Ext(path))
+	for _, sidecar := range []string{path + ".txt", base + ".txt"} {
+		data, err := os.ReadFile(sidecar)
+		if err != nil {
+			continue
+		}
+		if altText := strings.TrimSpace(string(data)); altText != "" {
+			return altText
+		}
+	}
+	return filepath.Base(base)
+}
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeGalleryFile grava um arquivo no diretório da galeria de teste
+func writeGalleryFile(t *testing.T, dir, name string, data []byte) {
+	t.Helper()
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newTestLocalSource cria uma galeria com duas fotos na raiz e uma no álbum
+// "festa", além de arquivos que não devem ser listados
+func newTestLocalSource(t *testing.T) (*LocalImageSource, string) {
+	t.Helper()
+	dir := t.TempDir()
+	writeGalleryFile(t, dir, "b.png", testImage(t, 1200, 800))
+	writeGalleryFile(t, dir, "a.png", testImage(t, 100, 100))
+	writeGalleryFile(t, dir, "a.png.txt", []byte("Os noivos na igreja\n"))
+	writeGalleryFile(t, dir, "festa/bolo.png", testImage(t, 100, 100))
+	writeGalleryFile(t, dir, "notas.txt", []byte("não é imagem"))
+	writeGalleryFile(t, dir, ".cache/oculta.png", testImage(t, 10, 10))
+	return NewLocalImageSource(dir), dir
+}
+
+func TestLocalListImages(t *testing.T) {
+	source, _ := newTestLocalSource(t)
+
+	images, err := source.ListImages(context.Background())
+	if err != nil {
+		t.Fatalf("ListImages: %v", err)
+	}
+	if len(images) != 3 {
+		t.Fatalf("ListImages retornou %d imagens, esperava 3: %+v", len(images), images)
+	}
+
+	byID := make(map[string]int)
+	for i, image := range images {
+		byID[image.ID] = i
+	}
+	tests := []struct {
+		rel     string
+		altText string
+		album   string
+	}{
+		{"a.png", "Os noivos na igreja", ""},
+		{"b.png", "b", ""},
+		{"festa/bolo.png", "bolo", "festa"},
+	}
+	for _, tt := range tests {
+		i, ok := byID[localImageID(tt.rel)]
+		if !ok {
+			t.Errorf("%s: ID derivado do caminho não está na listagem", tt.rel)
+			continue
+		}
+		image := images[i]
+		if image.AltText != tt.altText || image.Album != tt.album || image.MimeType != "image/png" {
+			t.Errorf("%s: alt %q, álbum %q, tipo %q; esperava %q, %q", tt.rel, image.AltText, image.Album, image.MimeType, tt.altText, tt.album)
+		}
+		if image.Checksum == "" || image.ModifiedTime.IsZero() {
+			t.Errorf("%s: sem versão: %+v", tt.rel, image)
+		}
+	}
+}
+
+func TestLocalListImagesIsCached(t *testing.T) {
+	source, dir := newTestLocalSource(t)
+	ctx := context.Background()
+
+	images, err := source.ListImages(ctx)
+	if err != nil {
+		t.Fatalf("ListImages: %v", err)
+	}
+	before, err := source.GetImage(ctx, localImageID("a.png"))
+	if err != nil {
+		t.Fatalf("GetImage: %v", err)
+	}
+
+	// Alterações no diretório só aparecem depois do TTL ou de um Refresh
+	writeGalleryFile(t, dir, "nova.png", testImage(t, 10, 10))
+	writeGalleryFile(t, dir, "a.png", testImage(t, 200, 200))
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "a.png"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	if cached, _ := source.ListImages(ctx); len(cached) != len(images) {
+		t.Errorf("listagem em cache mudou antes do Refresh: %d imagens", len(cached))
+	}
+
+	if err := source.Refresh(ctx); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if _, err := source.GetImage(ctx, localImageID("nova.png")); err != nil {
+		t.Errorf("GetImage da foto nova depois do Refresh: %v", err)
+	}
+	after, err := source.GetImage(ctx, localImageID("a.png"))
+	if err != nil {
+		t.Fatalf("GetImage depois do Refresh: %v", err)
+	}
+	if after.ID != before.ID || after.Checksum == before.Checksum {
+		t.Errorf("foto substituída: ID %q -> %q, versão %q -> %q; esperava o mesmo ID e outra versão", before.ID, after.ID, before.Checksum, after.Checksum)
+	}
+}
+
+func TestLocalOpenImage(t *testing.T) {
+	source, dir := newTestLocalSource(t)
+	ctx := context.Background()
+	id := localImageID("b.png")
+
+	// A miniatura é um JPEG reduzido, não o original
+	body, contentType, err := source.OpenImage(ctx, id, ImageSizeThumbnail)
+	if err != nil {
+		t.Fatalf("OpenImage miniatura: %v", err)
+	}
+	thumbnail, _ := io.ReadAll(body)
+	body.Close()
+	config, format, err := image.DecodeConfig(bytes.NewReader(thumbnail))
+	if err != nil || format != "jpeg" || contentType != ImageFormatJPEG {
+		t.Fatalf("miniatura em %q (%s): %v", format, contentType, err)
+	}
+	if config.Width != localThumbnailWidth || config.Height != localThumbnailWidth*800/1200 {
+		t.Errorf("miniatura com %dx%d, esperava largura %d", config.Width, config.Height, localThumbnailWidth)
+	}
+
+	body, contentType, err = source.OpenImage(ctx, id, ImageSizeOriginal)
+	if err != nil {
+		t.Fatalf("OpenImage original: %v", err)
+	}
+	original, _ := io.ReadAll(body)
+	body.Close()
+	want, _ := os.ReadFile(filepath.Join(dir, "b.png"))
+	if !bytes.Equal(original, want) || contentType != "image/png" {
+		t.Errorf("original com %d bytes (%s), esperava o arquivo com %d bytes", len(original), contentType, len(want))
+	}
+
+	// Só arquivos listados podem ser abertos, mesmo que o ID decodifique
+	// para um caminho existente
+	secret := filepath.Join(filepath.Dir(dir), filepath.Base(dir)+"-segredo.png")
+	if err := os.WriteFile(secret, testImage(t, 10, 10), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Remove(secret) })
+	for _, rel := range []string{"notas.txt", ".cache/oculta.png", "../" + filepath.Base(secret), "inexistente.png"} {
+		if _, _, err := source.OpenImage(ctx, localImageID(rel), ImageSizeOriginal); !errors.Is(err, ErrImageNotFound) {
+			t.Errorf("OpenImage(%s): esperava ErrImageNotFound, obteve %v", rel, err)
+		}
+	}
+}
@@ -0,0 +1,368 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kollinn/casamento-mari-kollinn/internal/config"
+	"github.com/kollinn/casamento-mari-kollinn/internal/model"
+	"golang.org/x/sync/singleflight"
+)
+
+// defaultS3ListTTL é a validade da listagem do bucket quando S3_CACHE_TTL não
+// é informado
+const defaultS3ListTTL = 5 * time.Minute
+
+// s3MetadataConcurrency limita os HEADs simultâneos para ler textos alternativos
+const s3MetadataConcurrency = 8
+
+// s3ListResult representa a resposta XML do ListObjectsV2
+type s3ListResult struct {
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+	Contents              []struct {
+		Key          string    `xml:"Key"`
+		LastModified time.Time `xml:"LastModified"`
+		ETag         string    `xml:"ETag"`
+		Size         int64     `xml:"Size"`
+	} `xml:"Contents"`
+}
+
+// S3ImageSource implementa ImageSource usando um bucket compatível com S3
+// (AWS S3, MinIO, Cloudflare R2, etc.) com endereçamento path-style
+type S3ImageSource struct {
+	config     config.S3Config
+	client     *http.Client
+	imageCache []model.DriveImage
+	fetchedAt  time.Time
+	altTexts   map[string]string // chave+ETag -> texto alternativo
+	cacheLock  sync.RWMutex
+	listGroup  singleflight.Group
+}
+
+// NewS3ImageSource cria uma fonte de imagens a partir de um bucket S3
+func NewS3ImageSource(s3Config config.S3Config) *S3ImageSource {
+	if s3Config.Region == "" {
+		s3Config.Region = "us-east-1"
+	}
+	if s3Config.Endpoint == "" {
+		s3Config.Endpoint = "https://s3." + s3Config.Region + ".amazonaws.com"
+	}
+	s3Config.Endpoint = strings.TrimSuffix(s3Config.Endpoint, "/")
+	if s3Config.CacheTTL <= 0 {
+		s3Config.CacheTTL = defaultS3ListTTL
+	}
+
+	log.Printf("Inicializando galeria S3 no bucket %s (%s)", s3Config.Bucket, s3Config.Endpoint)
+
+	return &S3ImageSource{
+		config:   s3Config,
+		client:   &http.Client{Timeout: 30 * time.Second},
+		altTexts: make(map[string]string),
+	}
+}
+
+// ListImages implementa ImageSource listando os objetos de imagem do bucket
+func (ss *S3ImageSource) ListImages(ctx context.Context) ([]model.DriveImage, error) {
+	ss.cacheLock.RLock()
+	if ss.imageCache != nil && time.Since(ss.fetchedAt) < ss.config.CacheTTL {
+		images := ss.imageCache
+		ss.cacheLock.RUnlock()
+		return images, nil
+	}
+	ss.cacheLock.RUnlock()
+
+	// Requisições simultâneas compartilham uma única listagem do bucket
+	result, err, _ := ss.listGroup.Do("list", func() (interface{}, error) {
+		listCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 60*time.Second)
+		defer cancel()
+		return ss.listAll(listCtx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.([]model.DriveImage), nil
+}
+
+// listAll percorre todas as páginas do ListObjectsV2 e atualiza o cache
+func (ss *S3ImageSource) listAll(ctx context.Context) ([]model.DriveImage, error) {
+	log.Printf("Listando objetos do bucket %s com prefixo %q", ss.config.Bucket, ss.config.Prefix)
+
+	var images []model.DriveImage
+	var pending []s3PendingAltText
+	continuationToken := ""
+	for {
+		result, err := ss.listObjects(ctx, continuationToken)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, object := range result.Contents {
+			mimeType, ok := localImageExtensions[strings.ToLower(path.Ext(object.Key))]
+			if !ok {
+				continue
+			}
+
+			id := s3ObjectID(object.Key)
+			image := model.DriveImage{
+				ID:            id,
+				Name:          path.Base(object.Key),
+				MimeType:      mimeType,
+				WebViewLink:   "/api/images/" + id + "/proxy?size=large",
+				ThumbnailLink: "/api/images/" + id + "/proxy",
+				Checksum:      strings.Trim(object.ETag, "\""),
+				ModifiedTime:  object.LastModified,
+			}
+			// "Pastas" dentro do prefixo viram álbuns
+			if album := path.Dir(strings.TrimPrefix(object.Key, ss.config.Prefix)); album != "." && album != "/" {
+				image.Album = strings.Trim(album, "/")
+				image.AlbumID = albumID(image.Album)
+			}
+
+			// Sem metadado em cache, o texto alternativo é lido depois da listagem
+			if altText, ok := ss.cachedAltText(object.Key, object.ETag); ok {
+				image.AltText = altText
+			} else {
+				image.AltText = s3FallbackAltText(object.Key)
+				pending = append(pending, s3PendingAltText{index: len(images), key: object.Key, etag: object.ETag})
+			}
+			images = append(images, image)
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		continuationToken = result.NextContinuationToken
+	}
+
+	ss.loadAltTexts(ctx, images, pending)
+
+	sortImages(images)
+
+	ss.cacheLock.Lock()
+	ss.imageCache = images
+	ss.fetchedAt = time.Now()
+	ss.cacheLock.Unlock()
+
+	log.Printf("Encontradas %d imagens no bucket", len(images))
+	return images, nil
+}
+
+// Refresh implementa Refresher descartando a listagem em cache
+func (ss *S3ImageSource) Refresh(ctx context.Context) error {
+	ss.cacheLock.Lock()
+	ss.fetchedAt = time.Time{}
+	ss.cacheLock.Unlock()
+
+	_, err := ss.ListImages(ctx)
+	return err
+}
+
+// GetImage implementa ImageSource
+func (ss *S3ImageSource) GetImage(ctx context.Context, id string) (*model.DriveImage, error) {
+	images, err := ss.ListImages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return findImage(images, id)
+}
+
+// OpenImage implementa ImageSource baixando o objeto com um GET assinado.
+// Os objetos são servidos sempre no tamanho original.
+func (ss *S3ImageSource) OpenImage(ctx context.Context, id string, size string) (io.ReadCloser, string, error) {
+	// O ID decodifica para qualquer chave: só objetos de imagem que aparecem
+	// na listagem podem ser baixados, para o proxy não expor o resto do bucket
+	key, err := s3ObjectKey(id)
+	if err != nil || !strings.HasPrefix(key, ss.config.Prefix) {
+		return nil, "", ErrImageNotFound
+	}
+	if _, ok := localImageExtensions[strings.ToLower(path.Ext(key))]; !ok {
+		return nil, "", ErrImageNotFound
+	}
+	if _, err := ss.GetImage(ctx, id); err != nil {
+		return nil, "", err
+	}
+
+	resp, err := ss.do(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, "", ErrImageNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, "", fmt.Errorf("erro do S3 ao baixar %s: %s", key, resp.Status)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if !strings.HasPrefix(contentType, "image/") {
+		contentType = localImageExtensions[strings.ToLower(path.Ext(key))]
+	}
+
+	return resp.Body, contentType, nil
+}
+
+// listObjects busca uma página do ListObjectsV2
+func (ss *S3ImageSource) listObjects(ctx context.Context, continuationToken string) (*s3ListResult, error) {
+	query := url.Values{}
+	query.Set("list-type", "2")
+	if ss.config.Prefix != "" {
+		query.Set("prefix", ss.config.Prefix)
+	}
+	if continuationToken != "" {
+		query.Set("continuation-token", continuationToken)
+	}
+
+	resp, err := ss.do(ctx, http.MethodGet, "", query)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("erro do S3 ao listar objetos: %s", resp.Status)
+	}
+
+	var result s3ListResult
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("erro ao decodificar listagem do S3: %v", err)
+	}
+	return &result, nil
+}
+
+// s3PendingAltText é um objeto listado cujo texto alternativo ainda não é conhecido
+type s3PendingAltText struct {
+	index int // posição da imagem na listagem
+	key   string
+	etag  string
+}
+
+// loadAltTexts lê os textos alternativos pendentes com HEADs em paralelo,
+// limitados a s3MetadataConcurrency. Uma falha mantém o nome do arquivo como
+// texto alternativo e não interrompe a listagem; o HEAD é repetido na próxima.
+func (ss *S3ImageSource) loadAltTexts(ctx context.Context, images []model.DriveImage, pending []s3PendingAltText) {
+	if len(pending) == 0 {
+		return
+	}
+
+	slots := make(chan struct{}, s3MetadataConcurrency)
+	var wg sync.WaitGroup
+	for _, p := range pending {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(p s3PendingAltText) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			altText, err := ss.objectAltText(ctx, p.key, p.etag)
+			if err != nil {
+				log.Printf("Aviso: erro ao ler metadados de %s: %v", p.key, err)
+				return
+			}
+			// Cada goroutine escreve em uma posição diferente
+			images[p.index].AltText = altText
+		}(p)
+	}
+	wg.Wait()
+}
+
+// cachedAltText retorna o texto alternativo já lido para a chave+ETag
+func (ss *S3ImageSource) cachedAltText(key, etag string) (string, bool) {
+	ss.cacheLock.RLock()
+	defer ss.cacheLock.RUnlock()
+	altText, ok := ss.altTexts[key+"|"+etag]
+	return altText, ok
+}
+
+// s3FallbackAltText usa o nome do arquivo sem a extensão, para objetos sem metadado
+func s3FallbackAltText(key string) string {
+	return strings.TrimSuffix(path.Base(key), path.Ext(key))
+}
+
+// objectAltText lê o texto alternativo do metadado x-amz-meta-alt-text.
+// O resultado é guardado por chave+ETag para evitar um HEAD por listagem.
+func (ss *S3ImageSource) objectAltText(ctx context.Context, key, etag string) (string, error) {
+	resp, err := ss.do(ctx, http.MethodHead, key, nil)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("erro do S3 ao ler metadados: %s", resp.Status)
+	}
+
+	altText := decodeS3Metadata(resp.Header.Get("X-Amz-Meta-Alt-Text"))
+	if altText == "" {
+		altText = s3FallbackAltText(key)
+	}
+
+	ss.cacheLock.Lock()
+	ss.altTexts[key+"|"+etag] = altText
+	ss.cacheLock.Unlock()
+
+	return altText, nil
+}
+
+// decodeS3Metadata decodifica um metadado do S3. Os metadados só aceitam
+// ASCII, então os SDKs e o console gravam textos acentuados no formato da
+// RFC 2047 ("=?UTF-8?B?...?="). Um valor mal formado é mantido como veio.
+func decodeS3Metadata(value string) string {
+	decoded, err := new(mime.WordDecoder).DecodeHeader(value)
+	if err != nil {
+		log.Printf("Aviso: metadado do S3 mal codificado (%q): %v", value, err)
+		return value
+	}
+	return decoded
+}
+
+// do executa uma requisição assinada contra o bucket
+func (ss *S3ImageSource) do(ctx context.Context, method, key string, query url.Values) (*http.Response, error) {
+	escapedPath := "/" + s3URIEncode(ss.config.Bucket, true)
+	if key != "" {
+		escapedPath += "/" + s3URIEncode(key, false)
+	}
+
+	rawURL := ss.config.Endpoint + escapedPath
+	if len(query) > 0 {
+		rawURL += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao criar requisição S3: %v", err)
+	}
+	signS3Request(req, ss.config.Region, ss.config.AccessKeyID, ss.config.SecretAccessKey, time.Now())
+
+	resp, err := ss.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("erro na requisição S3: %v", err)
+	}
+	return resp, nil
+}
+
+// s3ObjectID gera um ID seguro para URLs a partir da chave do objeto
+func s3ObjectID(key string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(key))
+}
+
+// s3ObjectKey recupera a chave do objeto a partir do ID
+func s3ObjectKey(id string) (string, error) {
+	key, err := base64.RawURLEncoding.DecodeString(id)
+	if err != nil {
+		return "", err
+	}
+	return string(key), nil
+}
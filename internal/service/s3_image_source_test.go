@@ -0,0 +1,401 @@
+package service
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kollinn/casamento-mari-kollinn/internal/config"
+)
+
+// fakeS3Object é um objeto guardado pelo bucket de teste
+type fakeS3Object struct {
+	key     string
+	etag    string
+	altText string
+	failing bool // HEAD responde 500
+}
+
+// fakeS3 simula o subconjunto da API do S3 usado por S3ImageSource:
+// ListObjectsV2 paginado, HEAD com metadados e GET do objeto
+type fakeS3 struct {
+	t        *testing.T
+	bucket   string
+	pageSize int
+	objects  []fakeS3Object
+
+	mutex    sync.Mutex
+	heads    map[string]int
+	lists    int
+	inFlight atomic.Int32
+	maxHeads atomic.Int32
+}
+
+func newFakeS3(t *testing.T, objects []fakeS3Object) (*fakeS3, *httptest.Server) {
+	fake := &fakeS3{t: t, bucket: "fotos", pageSize: 2, objects: objects, heads: make(map[string]int)}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, server
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ") {
+		f.t.Errorf("requisição sem assinatura: %s %s", r.Method, r.URL)
+	}
+
+	key := strings.TrimPrefix(r.URL.Path, "/"+f.bucket)
+	key = strings.TrimPrefix(key, "/")
+	if key == "" {
+		f.list(w, r)
+		return
+	}
+
+	object, ok := f.find(key)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodHead:
+		f.head(w, object)
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "image/jpeg")
+		io.WriteString(w, "conteudo de "+object.key)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("list-type") != "2" {
+		f.t.Errorf("listagem sem list-type=2: %s", r.URL)
+	}
+	f.mutex.Lock()
+	f.lists++
+	objects := f.objects
+	f.mutex.Unlock()
+
+	start := 0
+	if token := r.URL.Query().Get("continuation-token"); token != "" {
+		fmt.Sscanf(token, "page-%d", &start)
+	}
+	end := min(start+f.pageSize, len(objects))
+
+	var body strings.Builder
+	body.WriteString(`<?xml version="1.0" encoding="UTF-8"?><ListBucketResult>`)
+	for _, object := range objects[start:end] {
+		fmt.Fprintf(&body, "<Contents><Key>%s</Key><LastModified>2026-05-01T12:00:00.000Z</LastModified><ETag>&quot;%s&quot;</ETag><Size>10</Size></Contents>",
+			xmlEscape(object.key), object.etag)
+	}
+	if end < len(objects) {
+		fmt.Fprintf(&body, "<IsTruncated>true</IsTruncated><NextContinuationToken>page-%d</NextContinuationToken>", end)
+	} else {
+		body.WriteString("<IsTruncated>false</IsTruncated>")
+	}
+	body.WriteString("</ListBucketResult>")
+
+	w.Header().Set("Content-Type", "application/xml")
+	io.WriteString(w, body.String())
+}
+
+func (f *fakeS3) head(w http.ResponseWriter, object fakeS3Object) {
+	current := f.inFlight.Add(1)
+	defer f.inFlight.Add(-1)
+	for {
+		peak := f.maxHeads.Load()
+		if current <= peak || f.maxHeads.CompareAndSwap(peak, current) {
+			break
+		}
+	}
+
+	f.mutex.Lock()
+	f.heads[object.key]++
+	f.mutex.Unlock()
+
+	// Dá tempo para os HEADs se sobreporem
+	time.Sleep(5 * time.Millisecond)
+
+	if object.failing {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if object.altText != "" {
+		w.Header().Set("X-Amz-Meta-Alt-Text", object.altText)
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (f *fakeS3) find(key string) (fakeS3Object, bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for _, object := range f.objects {
+		if object.key == key {
+			return object, true
+		}
+	}
+	return fakeS3Object{}, false
+}
+
+func (f *fakeS3) headCount(key string) int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.heads[key]
+}
+
+func (f *fakeS3) listCount() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.lists
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+func newTestS3Source(server *httptest.Server) *S3ImageSource {
+	return NewS3ImageSource(config.S3Config{
+		Endpoint:        server.URL,
+		Region:          "us-east-1",
+		Bucket:          "fotos",
+		AccessKeyID:     "minio",
+		SecretAccessKey: "minio-secret",
+	})
+}
+
+func TestS3ListImagesReadsAltTextFromMetadata(t *testing.T) {
+	fake, server := newFakeS3(t, []fakeS3Object{
+		{key: "cerimonia/altar.jpg", etag: "e1", altText: "Noivos no altar"},
+		{key: "festa/bolo.png", etag: "e2"},
+		{key: "leia-me.txt", etag: "e3"},
+		{key: "festa/danca.jpg", etag: "e4", failing: true},
+		{key: "convite.webp", etag: "e5", altText: "Convite"},
+	})
+	source := newTestS3Source(server)
+
+	images, err := source.ListImages(context.Background())
+	if err != nil {
+		t.Fatalf("ListImages: %v", err)
+	}
+	if len(images) != 4 {
+		t.Fatalf("esperava 4 imagens (o .txt é ignorado), obteve %d", len(images))
+	}
+
+	byName := make(map[string]string)
+	albums := make(map[string]string)
+	for _, image := range images {
+		byName[image.Name] = image.AltText
+		albums[image.Name] = image.Album
+	}
+
+	want := map[string]string{
+		"altar.jpg":    "Noivos no altar",
+		"bolo.png":     "bolo",  // sem metadado
+		"danca.jpg":    "danca", // HEAD falhou: nome do arquivo
+		"convite.webp": "Convite",
+	}
+	for name, altText := range want {
+		if byName[name] != altText {
+			t.Errorf("texto alternativo de %s = %q, esperava %q", name, byName[name], altText)
+		}
+	}
+	if albums["altar.jpg"] != "cerimonia" || albums["bolo.png"] != "festa" || albums["convite.webp"] != "" {
+		t.Errorf("álbuns inesperados: %v", albums)
+	}
+	if fake.headCount("leia-me.txt") != 0 {
+		t.Errorf("não deveria ler metadados de objetos que não são imagens")
+	}
+}
+
+func TestS3ListImagesDecodesEncodedAltText(t *testing.T) {
+	_, server := newFakeS3(t, []fakeS3Object{
+		{key: "base64.jpg", etag: "e1", altText: "=?UTF-8?B?Tm9pdm9zIG5vIGFsdGFyIGRhIGNhcGVsYSBkZSBTw6NvIEpvc8Op?="},
+		{key: "quoted.jpg", etag: "e2", altText: "=?utf-8?q?Primeira_dan=C3=A7a?="},
+		{key: "latin1.jpg", etag: "e3", altText: "=?ISO-8859-1?Q?Cerim=F4nia?="},
+		{key: "ascii.jpg", etag: "e4", altText: "Bolo de casamento"},
+		{key: "invalido.jpg", etag: "e5", altText: "=?UTF-8?B?###?="},
+	})
+	source := newTestS3Source(server)
+
+	images, err := source.ListImages(context.Background())
+	if err != nil {
+		t.Fatalf("ListImages: %v", err)
+	}
+
+	want := map[string]string{
+		"base64.jpg":   "Noivos no altar da capela de São José",
+		"quoted.jpg":   "Primeira dança",
+		"latin1.jpg":   "Cerimônia",
+		"ascii.jpg":    "Bolo de casamento",
+		"invalido.jpg": "=?UTF-8?B?###?=",
+	}
+	for _, image := range images {
+		if image.AltText != want[image.Name] {
+			t.Errorf("texto alternativo de %s = %q, esperava %q", image.Name, image.AltText, want[image.Name])
+		}
+	}
+}
+
+func TestS3ListImagesCachesMetadataAndRetriesFailures(t *testing.T) {
+	fake, server := newFakeS3(t, []fakeS3Object{
+		{key: "altar.jpg", etag: "e1", altText: "Noivos no altar"},
+		{key: "danca.jpg", etag: "e2", failing: true},
+	})
+	source := newTestS3Source(server)
+
+	if _, err := source.ListImages(context.Background()); err != nil {
+		t.Fatalf("ListImages: %v", err)
+	}
+	if err := source.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+
+	if n := fake.headCount("altar.jpg"); n != 1 {
+		t.Errorf("altar.jpg recebeu %d HEADs; o texto alternativo deveria vir do cache", n)
+	}
+	if n := fake.headCount("danca.jpg"); n != 2 {
+		t.Errorf("danca.jpg recebeu %d HEADs; a falha deveria ser repetida na próxima listagem", n)
+	}
+}
+
+func TestS3ListImagesHonoursConfiguredCacheTTL(t *testing.T) {
+	tests := []struct {
+		name      string
+		ttl       time.Duration
+		wantLists int
+	}{
+		{"dentro da validade", time.Hour, 1},
+		{"validade expirada", time.Millisecond, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, server := newFakeS3(t, []fakeS3Object{{key: "altar.jpg", etag: "e1"}})
+			source := newTestS3Source(server)
+			source.config.CacheTTL = tt.ttl
+
+			for i := 0; i < 2; i++ {
+				if _, err := source.ListImages(context.Background()); err != nil {
+					t.Fatalf("ListImages: %v", err)
+				}
+				time.Sleep(5 * time.Millisecond)
+			}
+
+			if n := fake.listCount(); n != tt.wantLists {
+				t.Errorf("o bucket foi listado %d vezes, esperava %d", n, tt.wantLists)
+			}
+		})
+	}
+}
+
+func TestS3ListImagesBoundsConcurrentHeads(t *testing.T) {
+	var objects []fakeS3Object
+	for i := 0; i < 40; i++ {
+		objects = append(objects, fakeS3Object{key: fmt.Sprintf("foto-%02d.jpg", i), etag: fmt.Sprintf("e%d", i)})
+	}
+	fake, server := newFakeS3(t, objects)
+	fake.pageSize = 1000
+	source := newTestS3Source(server)
+
+	images, err := source.ListImages(context.Background())
+	if err != nil {
+		t.Fatalf("ListImages: %v", err)
+	}
+	if len(images) != len(objects) {
+		t.Fatalf("esperava %d imagens, obteve %d", len(objects), len(images))
+	}
+
+	peak := fake.maxHeads.Load()
+	if peak > s3MetadataConcurrency {
+		t.Errorf("%d HEADs simultâneos, o limite é %d", peak, s3MetadataConcurrency)
+	}
+	if peak < 2 {
+		t.Errorf("os HEADs deveriam ser feitos em paralelo (máximo observado: %d)", peak)
+	}
+}
+
+func TestS3ListImagesFailsWhenListingFails(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "AccessDenied", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	if _, err := newTestS3Source(server).ListImages(context.Background()); err == nil {
+		t.Fatal("esperava erro quando o ListObjectsV2 é recusado")
+	}
+}
+
+func TestS3OpenImage(t *testing.T) {
+	_, server := newFakeS3(t, []fakeS3Object{{key: "festa/bolo.jpg", etag: "e1"}})
+	source := newTestS3Source(server)
+
+	body, contentType, err := source.OpenImage(context.Background(), s3ObjectID("festa/bolo.jpg"), "")
+	if err != nil {
+		t.Fatalf("OpenImage: %v", err)
+	}
+	defer body.Close()
+	data, _ := io.ReadAll(body)
+	if contentType != "image/jpeg" || string(data) != "conteudo de festa/bolo.jpg" {
+		t.Errorf("OpenImage retornou %q (%s)", data, contentType)
+	}
+
+	if _, _, err := source.OpenImage(context.Background(), s3ObjectID("nao-existe.jpg"), ""); !errors.Is(err, ErrImageNotFound) {
+		t.Errorf("objeto ausente: esperava ErrImageNotFound, obteve %v", err)
+	}
+}
+
+func TestS3OpenImageOnlyServesListedImages(t *testing.T) {
+	fake, server := newFakeS3(t, []fakeS3Object{
+		{key: "festa/bolo.jpg", etag: "e1"},
+		{key: "festa/lista-de-convidados.csv", etag: "e2"},
+		{key: "privado/contrato.jpg", etag: "e3"},
+	})
+	source := newTestS3Source(server)
+	source.config.Prefix = "festa/"
+
+	// Um objeto enviado depois da listagem só aparece depois de um Refresh
+	if _, err := source.ListImages(context.Background()); err != nil {
+		t.Fatalf("ListImages: %v", err)
+	}
+	fake.mutex.Lock()
+	fake.objects = append(fake.objects, fakeS3Object{key: "festa/nova.jpg", etag: "e4"})
+	fake.mutex.Unlock()
+
+	tests := []struct {
+		name string
+		id   string
+	}{
+		{"arquivo que não é imagem", s3ObjectID("festa/lista-de-convidados.csv")},
+		{"fora do prefixo", s3ObjectID("privado/contrato.jpg")},
+		{"subindo de diretório", s3ObjectID("festa/../privado/contrato.jpg")},
+		{"ainda não listado", s3ObjectID("festa/nova.jpg")},
+		{"ID inválido", "%%%"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _, err := source.OpenImage(context.Background(), tt.id, "")
+			if err == nil {
+				body.Close()
+			}
+			if !errors.Is(err, ErrImageNotFound) {
+				t.Errorf("esperava ErrImageNotFound, obteve %v", err)
+			}
+		})
+	}
+
+	body, _, err := source.OpenImage(context.Background(), s3ObjectID("festa/bolo.jpg"), "")
+	if err != nil {
+		t.Fatalf("foto listada: %v", err)
+	}
+	body.Close()
+}
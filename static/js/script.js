@@ -1,3 +1,22 @@
+// Quantidade de imagens solicitadas por página à API
+const GALLERY_PAGE_SIZE = 30;
+
+// Larguras geradas pelo proxy para as miniaturas e para o modal; outras
+// larguras são arredondadas pelo servidor (ver service.ImageWidths)
+const GALLERY_THUMB_WIDTHS = [320, 480, 640, 960];
+const GALLERY_THUMB_SIZES = '(max-width: 768px) 50vw, 300px';
+const MODAL_IMAGE_WIDTHS = [800, 1200, 1600, 2400];
+
+// Estado da paginação da galeria
+const galleryState = {
+    nextPage: 1,
+    hasMore: true,
+    loading: false,
+    loadedCount: 0,
+    observer: null,
+    albumId: ''
+};
+
 async function loadImages() {
     console.log("Iniciando carregamento das imagens");
     
@@ -15,10 +34,36 @@ async function loadImages() {
     errorElement.style.display = 'none';
     galleryContainer.innerHTML = '';
     
+    // Reinicia a paginação
+    galleryState.nextPage = 1;
+    galleryState.hasMore = true;
+    galleryState.loadedCount = 0;
+    
+    await loadNextPage();
+    
+    // Carrega as próximas páginas conforme o usuário rola a página
+    setupInfiniteScroll(galleryContainer);
+}
+
+// Busca a próxima página de imagens na API e adiciona à galeria
+async function loadNextPage() {
+    if (galleryState.loading || !galleryState.hasMore) {
+        return;
+    }
+    galleryState.loading = true;
+    
+    const loadingElement = document.getElementById('loading');
+    const errorElement = document.getElementById('error');
+    const galleryContainer = document.getElementById('gallery-container');
+    
     try {
         // Fazendo requisição para a API
-        console.log("Requisitando imagens da API...");
-        const response = await fetch('/api/images');
+        const page = galleryState.nextPage;
+        console.log(`Requisitando página ${page} de imagens da API...`);
+        const baseUrl = galleryState.albumId
+            ? `/api/albums/${encodeURIComponent(galleryState.albumId)}/images`
+            : '/api/images';
+        const response = await fetch(`${baseUrl}?page=${page}&pageSize=${GALLERY_PAGE_SIZE}`);
         console.log("Resposta da API:", response.status, response.statusText);
         
         if (!response.ok) {
@@ -27,262 +72,323 @@ async function loadImages() {
         
         // Convertendo resposta para JSON
         const data = await response.json();
-        console.log(`Recebidas ${data.length} imagens da API`);
+        const images = data.images || [];
+        console.log(`Recebidas ${images.length} imagens da API (total: ${data.total})`);
+        
+        galleryState.nextPage = page + 1;
+        galleryState.hasMore = data.hasMore;
         
         // Esconde o loader
         loadingElement.style.display = 'none';
         
         // Se não houver imagens, mostra mensagem
-        if (!data || data.length === 0) {
+        if (galleryState.loadedCount === 0 && images.length === 0) {
             errorElement.textContent = "Nenhuma imagem encontrada.";
             errorElement.style.display = 'block';
             return;
         }
         
-        // Cria todos os contêineres primeiro, depois carrega as imagens em lotes
-        const imageElements = [];
+        const imageElements = renderImagePlaceholders(images, galleryContainer, galleryState.loadedCount);
+        galleryState.loadedCount += images.length;
         
-        // Renderiza cada imagem na galeria
-        data.forEach((image, index) => {
-            console.log(`Processando metadados da imagem ${index}:`, image);
-            
-            // Cria container da imagem
-            const imgElement = document.createElement('div');
-            imgElement.className = 'gallery-item';
-            
-            // Extrai o ID da imagem (diretamente ou do WebViewLink)
-            let imageId = image.ID || image.id;
-            
-            // Se não tiver ID direto, tenta extrair do WebViewLink
-            if (!imageId && image.WebViewLink) {
-                // Extrai o ID do WebViewLink usando regex para encontrar o ID na URL
-                const matches = image.WebViewLink.match(/\/d\/([^\/]+)/);
-                if (matches && matches[1]) {
-                    imageId = matches[1];
-                }
-            }
-            
-            if (!imageId) {
-                console.error(`Imagem ${index} sem ID válido:`, image);
-                imgElement.innerHTML = '<p>Imagem indisponível</p>';
-                galleryContainer.appendChild(imgElement);
-                return;
-            }
-            
-            // Adiciona um placeholder para a imagem
-            imgElement.innerHTML = '<p>Carregando...</p>';
-            galleryContainer.appendChild(imgElement);
-            
-            // Armazena os dados para carregar mais tarde
-            imageElements.push({
-                element: imgElement,
-                imageId,
-                index,
-                alt: image.Name || image.name || `Imagem ${index}`,
-                imageData: image
-            });
-        });
+        // Inicia o carregamento das imagens em lotes
+        loadImagesInBatches(imageElements);
         
-        // Função para criar timestamp
-        function getTimestamp() {
-            const now = new Date();
-            return `${now.getHours()}:${now.getMinutes()}:${now.getSeconds()}.${now.getMilliseconds()}`;
+    } catch (error) {
+        console.error("Erro ao carregar imagens:", error);
+        loadingElement.style.display = 'none';
+        errorElement.textContent = `Erro ao carregar imagens: ${error.message}`;
+        errorElement.style.display = 'block';
+    } finally {
+        galleryState.loading = false;
+    }
+}
+
+// Carrega os álbuns (subpastas) e cria as abas acima da galeria
+async function loadAlbums() {
+    const tabsContainer = document.getElementById('album-tabs');
+    if (!tabsContainer) return;
+    
+    try {
+        const response = await fetch('/api/albums');
+        if (!response.ok) {
+            throw new Error(`Erro HTTP: ${response.status}`);
         }
         
-        // Função para obter o thumbnailLink independente do formato de nome de propriedade
-        function getThumbnailLink(imageData) {
-            if (!imageData) return null;
-            
-            // Tenta diferentes formatos de propriedade que podem estar presentes nos dados da imagem
-            return imageData.ThumbnailLink || imageData.thumbnailLink || imageData.Thumbnaillink || imageData.thumbnaillink;
+        const albums = await response.json();
+        console.log(`Recebidos ${albums.length} álbuns da API`);
+        
+        // Sem álbuns, a galeria mostra apenas a lista completa
+        if (albums.length === 0) {
+            tabsContainer.style.display = 'none';
+            return;
         }
         
-        // Função para extrair o ID da imagem de uma URL
-        function extractImageId(url) {
-            if (!url) return null;
-            
-            // Para URLs de drive-storage
-            if (url.includes('drive-storage')) {
-                const match = url.match(/drive-storage\/([^\/=]+)/);
-                if (match && match[1]) return match[1];
+        tabsContainer.innerHTML = '';
+        const tabs = [{ ID: '', Name: 'Todas' }, ...albums];
+        tabs.forEach(album => {
+            const button = document.createElement('button');
+            button.className = 'album-tab';
+            button.textContent = album.ImageCount ? `${album.Name} (${album.ImageCount})` : album.Name;
+            if (album.ID === galleryState.albumId) {
+                button.classList.add('active');
             }
             
-            // Para URLs diretas do Google Drive
-            const driveMatch = url.match(/\/d\/([^\/]+)/);
-            if (driveMatch && driveMatch[1]) return driveMatch[1];
+            button.addEventListener('click', () => {
+                tabsContainer.querySelectorAll('.album-tab').forEach(tab => tab.classList.remove('active'));
+                button.classList.add('active');
+                galleryState.albumId = album.ID;
+                loadImages();
+            });
             
-            return null;
+            tabsContainer.appendChild(button);
+        });
+        tabsContainer.style.display = 'flex';
+    } catch (error) {
+        console.error("Erro ao carregar álbuns:", error);
+        tabsContainer.style.display = 'none';
+    }
+}
+
+// Observa o fim da galeria para carregar mais páginas automaticamente
+function setupInfiniteScroll(galleryContainer) {
+    if (galleryState.observer) {
+        galleryState.observer.disconnect();
+    }
+    
+    let sentinel = document.getElementById('gallery-sentinel');
+    if (!sentinel) {
+        sentinel = document.createElement('div');
+        sentinel.id = 'gallery-sentinel';
+        galleryContainer.after(sentinel);
+    }
+    
+    galleryState.observer = new IntersectionObserver(entries => {
+        if (entries.some(entry => entry.isIntersecting)) {
+            loadNextPage();
         }
+    }, { rootMargin: '600px' });
+    galleryState.observer.observe(sentinel);
+}
+
+// Cria os contêineres das imagens e retorna os dados para carregá-las depois
+function renderImagePlaceholders(data, galleryContainer, offset) {
+    const imageElements = [];
+    
+    // Renderiza cada imagem na galeria
+    data.forEach((image, i) => {
+        const index = offset + i;
+        console.log(`Processando metadados da imagem ${index}:`, image);
         
-        // Função para criar URL do proxy local
-        function createProxyUrl(imageData) {
-            if (!imageData) return null;
-            
-            const imageId = imageData.ID || imageData.id;
-            if (imageId) {
-                // Usa o proxy local para esta imagem
-                return `/api/images/${imageId}/proxy`;
+        // Cria container da imagem
+        const imgElement = document.createElement('div');
+        imgElement.className = 'gallery-item';
+        
+        // Extrai o ID da imagem (diretamente ou do WebViewLink)
+        let imageId = image.ID || image.id;
+        
+        // Se não tiver ID direto, tenta extrair do WebViewLink
+        if (!imageId && image.WebViewLink) {
+            // Extrai o ID do WebViewLink usando regex para encontrar o ID na URL
+            const matches = image.WebViewLink.match(/\/d\/([^\/]+)/);
+            if (matches && matches[1]) {
+                imageId = matches[1];
             }
-            
-            return null;
         }
         
-        // Função simplificada para carregar imagens
-        async function loadSingleImage({element, imageId, index, alt, imageData}) {
-            // Adiciona um placeholder enquanto carrega
-            element.innerHTML = '<p>Carregando...</p>';
-            
-            try {
-                // Cria a tag de imagem
-                const img = document.createElement('img');
-                img.alt = alt;
-                
-                // Usa o proxy local em vez de acessar diretamente o Google Drive
-                const proxyUrl = createProxyUrl(imageData);
-                
-                if (proxyUrl) {
-                    console.log(`Usando proxy local para imagem ${index}:`, proxyUrl);
-                    img.src = proxyUrl;
-                    
-                    // Promessa que resolve quando a imagem carrega ou rejeita em erro
-                    await new Promise((resolve, reject) => {
-                        img.onload = () => {
-                            // Limpa conteúdo atual e adiciona a imagem
-                            element.innerHTML = '';
-                            
-                            // Ao clicar na imagem, abre o modal
-                            img.onclick = () => {
-                                openModalWithProxy(imageData);
-                            };
-                            
-                            element.appendChild(img);
-                            console.log(`Imagem ${index} carregada com sucesso`);
-                            resolve();
-                        };
-                        
-                        img.onerror = (error) => {
-                            console.error(`Erro ao carregar imagem ${index}:`, error);
-                            reject(error);
-                        };
-                    });
-                } else {
-                    console.warn(`Sem ID para imagem ${index}, usando placeholder`);
-                    element.innerHTML = '<p>Imagem indisponível</p>';
-                }
-            } catch (error) {
-                console.error(`Erro ao carregar imagem ${index}:`, error);
-                element.innerHTML = '<p>Imagem indisponível</p>';
-            }
+        if (!imageId) {
+            console.error(`Imagem ${index} sem ID válido:`, image);
+            imgElement.innerHTML = '<p>Imagem indisponível</p>';
+            galleryContainer.appendChild(imgElement);
+            return;
         }
         
-        // Função para abrir o modal usando proxy
-        function openModalWithProxy(imageData) {
-            const modal = document.getElementById('imageModal');
-            const modalImg = document.getElementById('modalImg');
-            
-            if (!modal || !modalImg) {
-                console.error("Elementos do modal não encontrados");
-                return;
-            }
-            
-            // Mostra o modal com um estado de carregamento
-            modal.style.display = "block";
-            modalImg.style.display = "none";
-            
-            // Adiciona um loader ao modal enquanto a imagem carrega
-            const loader = document.createElement('div');
-            loader.className = 'loader';
-            loader.id = 'modal-loader';
-            modal.appendChild(loader);
+        // Adiciona um placeholder para a imagem
+        imgElement.innerHTML = '<p>Carregando...</p>';
+        galleryContainer.appendChild(imgElement);
+        
+        // Armazena os dados para carregar mais tarde
+        imageElements.push({
+            element: imgElement,
+            imageId,
+            index,
+            alt: image.Name || image.name || `Imagem ${index}`,
+            imageData: image
+        });
+    });
+    
+    return imageElements;
+}
+
+// Função para criar URL do proxy local
+function createProxyUrl(imageData) {
+    if (!imageData) return null;
+    
+    const imageId = imageData.ID || imageData.id;
+    if (imageId) {
+        // Usa o proxy local para esta imagem
+        return `/api/images/${imageId}/proxy`;
+    }
+    
+    return null;
+}
+
+// Monta o atributo srcset com as larguras geradas pelo proxy
+function createSrcset(proxyUrl, widths) {
+    return widths.map(width => `${proxyUrl}?w=${width} ${width}w`).join(', ');
+}
+
+// Função simplificada para carregar imagens
+async function loadSingleImage({element, imageId, index, alt, imageData}) {
+    // Adiciona um placeholder enquanto carrega
+    element.innerHTML = '<p>Carregando...</p>';
+    
+    try {
+        // Cria a tag de imagem
+        const img = document.createElement('img');
+        img.alt = alt;
+        
+        // Usa o proxy local em vez de acessar diretamente o Google Drive
+        const proxyUrl = createProxyUrl(imageData);
+        
+        if (proxyUrl) {
+            console.log(`Usando proxy local para imagem ${index}:`, proxyUrl);
             
-            // Obtém o ID da imagem
-            const imageId = imageData.ID || imageData.id;
+            // O servidor gera a largura adequada para cada tela
+            img.sizes = GALLERY_THUMB_SIZES;
+            img.srcset = createSrcset(proxyUrl, GALLERY_THUMB_WIDTHS);
+            img.src = `${proxyUrl}?w=${GALLERY_THUMB_WIDTHS[1]}`;
             
-            if (imageId) {
-                // URL para versão de alta resolução através do proxy
-                const proxyUrlHighRes = `/api/images/${imageId}/proxy?size=large`;
-                
-                // Definir atributos e fonte
-                modalImg.alt = imageData.Name || imageData.name || "";
-                modalImg.src = proxyUrlHighRes;
-                
-                // Handler para quando a imagem carregar
-                modalImg.onload = function() {
-                    // Remove o loader
-                    const modalLoader = document.getElementById('modal-loader');
-                    if (modalLoader) modalLoader.remove();
+            // Promessa que resolve quando a imagem carrega ou rejeita em erro
+            await new Promise((resolve, reject) => {
+                img.onload = () => {
+                    // Limpa conteúdo atual e adiciona a imagem
+                    element.innerHTML = '';
                     
-                    // Mostra a imagem
-                    modalImg.style.display = "block";
-                };
-                
-                // Handler para erros
-                modalImg.onerror = function() {
-                    // Remove o loader
-                    const modalLoader = document.getElementById('modal-loader');
-                    if (modalLoader) modalLoader.remove();
+                    // Ao clicar na imagem, abre o modal
+                    img.onclick = () => {
+                        openModalWithProxy(imageData);
+                    };
                     
-                    // Mostra mensagem de erro
-                    const errorMsg = document.createElement('p');
-                    errorMsg.innerText = "Não foi possível carregar a imagem.";
-                    errorMsg.style.color = "white";
-                    errorMsg.style.textAlign = "center";
-                    errorMsg.style.padding = "20px";
-                    modal.appendChild(errorMsg);
+                    element.appendChild(img);
+                    console.log(`Imagem ${index} carregada com sucesso`);
+                    resolve();
                 };
-            } else {
-                // Remove o loader
-                const modalLoader = document.getElementById('modal-loader');
-                if (modalLoader) modalLoader.remove();
                 
-                // Mostra mensagem de erro
-                const errorMsg = document.createElement('p');
-                errorMsg.innerText = "ID da imagem indisponível";
-                errorMsg.style.color = "white";
-                errorMsg.style.textAlign = "center";
-                errorMsg.style.padding = "20px";
-                modal.appendChild(errorMsg);
-            }
-            
-            // Configura o fechamento do modal ao clicar fora da imagem
-            modal.onclick = function(event) {
-                if (event.target === modal) {
-                    closeModal();
-                }
-            };
+                img.onerror = (error) => {
+                    console.error(`Erro ao carregar imagem ${index}:`, error);
+                    reject(error);
+                };
+            });
+        } else {
+            console.warn(`Sem ID para imagem ${index}, usando placeholder`);
+            element.innerHTML = '<p>Imagem indisponível</p>';
         }
+    } catch (error) {
+        console.error(`Erro ao carregar imagem ${index}:`, error);
+        element.innerHTML = '<p>Imagem indisponível</p>';
+    }
+}
+
+// Função para abrir o modal usando proxy
+function openModalWithProxy(imageData) {
+    const modal = document.getElementById('imageModal');
+    const modalImg = document.getElementById('modalImg');
+    
+    if (!modal || !modalImg) {
+        console.error("Elementos do modal não encontrados");
+        return;
+    }
+    
+    // Mostra o modal com um estado de carregamento
+    modal.style.display = "block";
+    modalImg.style.display = "none";
+    
+    // Adiciona um loader ao modal enquanto a imagem carrega
+    const loader = document.createElement('div');
+    loader.className = 'loader';
+    loader.id = 'modal-loader';
+    modal.appendChild(loader);
+    
+    // Obtém o ID da imagem
+    const imageId = imageData.ID || imageData.id;
+    
+    if (imageId) {
+        // URL para versão de alta resolução através do proxy
+        const proxyUrl = `/api/images/${imageId}/proxy`;
         
-        // Função simples para carregar imagens em lotes
-        async function loadImagesInBatches(elements, batchSize = 5, delay = 500) {
-            console.log(`Iniciando carregamento de imagens em lotes de ${batchSize} com delay de ${delay}ms`);
+        // Definir atributos e fonte
+        modalImg.alt = imageData.Name || imageData.name || "";
+        modalImg.sizes = '90vw';
+        modalImg.srcset = createSrcset(proxyUrl, MODAL_IMAGE_WIDTHS);
+        modalImg.src = `${proxyUrl}?size=large`;
+        
+        // Handler para quando a imagem carregar
+        modalImg.onload = function() {
+            // Remove o loader
+            const modalLoader = document.getElementById('modal-loader');
+            if (modalLoader) modalLoader.remove();
             
-            // Dividir elementos em lotes
-            for (let i = 0; i < elements.length; i += batchSize) {
-                const batch = elements.slice(i, i + batchSize);
-                console.log(`Carregando lote ${Math.floor(i/batchSize) + 1} de ${Math.ceil(elements.length/batchSize)}`);
-                
-                // Carregar lote atual
-                const promises = batch.map(item => loadSingleImage(item));
-                await Promise.allSettled(promises);
-                
-                // Esperar antes de carregar o próximo lote (se não for o último)
-                if (i + batchSize < elements.length) {
-                    await new Promise(resolve => setTimeout(resolve, delay));
-                }
-            }
+            // Mostra a imagem
+            modalImg.style.display = "block";
+        };
+        
+        // Handler para erros
+        modalImg.onerror = function() {
+            // Remove o loader
+            const modalLoader = document.getElementById('modal-loader');
+            if (modalLoader) modalLoader.remove();
             
-            console.log("Carregamento em lotes concluído");
+            // Mostra mensagem de erro
+            const errorMsg = document.createElement('p');
+            errorMsg.innerText = "Não foi possível carregar a imagem.";
+            errorMsg.style.color = "white";
+            errorMsg.style.textAlign = "center";
+            errorMsg.style.padding = "20px";
+            modal.appendChild(errorMsg);
+        };
+    } else {
+        // Remove o loader
+        const modalLoader = document.getElementById('modal-loader');
+        if (modalLoader) modalLoader.remove();
+        
+        // Mostra mensagem de erro
+        const errorMsg = document.createElement('p');
+        errorMsg.innerText = "ID da imagem indisponível";
+        errorMsg.style.color = "white";
+        errorMsg.style.textAlign = "center";
+        errorMsg.style.padding = "20px";
+        modal.appendChild(errorMsg);
+    }
+    
+    // Configura o fechamento do modal ao clicar fora da imagem
+    modal.onclick = function(event) {
+        if (event.target === modal) {
+            closeModal();
         }
+    };
+}
+
+// Função simples para carregar imagens em lotes
+async function loadImagesInBatches(elements, batchSize = 5, delay = 500) {
+    console.log(`Iniciando carregamento de imagens em lotes de ${batchSize} com delay de ${delay}ms`);
+    
+    // Dividir elementos em lotes
+    for (let i = 0; i < elements.length; i += batchSize) {
+        const batch = elements.slice(i, i + batchSize);
+        console.log(`Carregando lote ${Math.floor(i/batchSize) + 1} de ${Math.ceil(elements.length/batchSize)}`);
         
-        // Inicia o carregamento das imagens em lotes
-        loadImagesInBatches(imageElements);
+        // Carregar lote atual
+        const promises = batch.map(item => loadSingleImage(item));
+        await Promise.allSettled(promises);
         
-    } catch (error) {
-        console.error("Erro ao carregar imagens:", error);
-        loadingElement.style.display = 'none';
-        errorElement.textContent = `Erro ao carregar imagens: ${error.message}`;
-        errorElement.style.display = 'block';
+        // Esperar antes de carregar o próximo lote (se não for o último)
+        if (i + batchSize < elements.length) {
+            await new Promise(resolve => setTimeout(resolve, delay));
+        }
     }
+    
+    console.log("Carregamento em lotes concluído");
 }
 
 function closeModal() {
@@ -322,59 +428,413 @@ function criarFormularioDinamico() {
   formDinamico.innerHTML = `
     <h3>Respostas para Convidado</h3>
 
-    <p>Você é vegetariano/vegano?</p>
-    <label>
-      <input type="radio" name="vegetariano" value="Sim" required> 
-      Sim
-    </label>
-    <label>
-      <input type="radio" name="vegetariano" value="Não"> 
-      Não
-    </label>
-
-    <p>Você come frutos do mar?</p>
-    <label>
-      <input type="radio" name="frutosDoMar" value="Sim" required> 
-      Sim
-    </label>
-    <label>
-      <input type="radio" name="frutosDoMar" value="Não"> 
-      Não
-    </label>
-
-    <p>Se não come frutos do mar, prefere frango ou carne?</p>
-    <select name="preferencia">
-      <option value="Frango">Frango</option>
-      <option value="Carne">Carne</option>
-    </select>
+    <p>Código do convite</p>
+    <input type="text" name="codigo" maxlength="12" autocomplete="off" required>
+    <div id="convite"></div>
+
+    <div id="pessoas"></div>
+
+    <div id="campoAcompanhantes" style="display: none;">
+      <p>Quantos acompanhantes virão com vocês?</p>
+      <select name="acompanhantes"></select>
+    </div>
+    <div id="acompanhantes"></div>
+
+    <p>Telefone ou e-mail para contato (opcional)</p>
+    <input type="text" name="contato" maxlength="120">
+  `;
+
+  // O link do convite traz o código: /confirmar?code=XYZ
+  const campoCodigo = formDinamico.querySelector('input[name="codigo"]');
+  const codigo = new URLSearchParams(window.location.search).get('code');
+  if (codigo) {
+    campoCodigo.value = codigo;
+    carregarConvite(codigo);
+  }
+  campoCodigo.addEventListener('change', () => carregarConvite(campoCodigo.value));
+
+  const acompanhantes = formDinamico.querySelector('select[name="acompanhantes"]');
+  acompanhantes.addEventListener('change', () => criarBlocosAcompanhantes(parseInt(acompanhantes.value, 10) || 0));
+
+  mostrarConfirmacaoAnterior();
+}
+
+// Questionário feito a cada pessoa que vai comparecer, definido no servidor
+let questionario = null;
+
+// Busca o questionário em /api/rsvp/form antes de montar o primeiro convite
+async function carregarQuestionario() {
+  try {
+    const response = await fetch('/api/rsvp/form');
+    if (!response.ok) throw new Error(`status ${response.status}`);
+    questionario = await response.json();
+  } catch (error) {
+    console.error('Erro ao buscar questionário:', error);
+    document.getElementById('convite').innerHTML =
+      '<p class="error-message">Não foi possível carregar o formulário. Recarregue a página.</p>';
+  }
+}
+
+// Contador para gerar nomes únicos para os campos de cada pessoa
+let proximoBlocoPessoa = 0;
+
+// Cria o campo de uma pergunta do questionário conforme o tipo
+function criarCampoPergunta(pergunta, idBloco) {
+  const div = document.createElement('div');
+  div.className = 'pergunta';
+  div.dataset.id = pergunta.id;
+
+  const titulo = document.createElement('p');
+  titulo.textContent = pergunta.required ? pergunta.label : `${pergunta.label} (opcional)`;
+  div.appendChild(titulo);
+
+  switch (pergunta.type) {
+    case 'radio':
+      pergunta.options.forEach(opcao => {
+        const label = document.createElement('label');
+        const input = document.createElement('input');
+        input.type = 'radio';
+        input.name = `${pergunta.id}-${idBloco}`;
+        input.value = opcao;
+        label.append(input, ` ${opcao}`);
+        div.appendChild(label);
+      });
+      break;
+    case 'select': {
+      const select = document.createElement('select');
+      select.add(new Option('Selecione', ''));
+      pergunta.options.forEach(opcao => select.add(new Option(opcao, opcao)));
+      div.appendChild(select);
+      break;
+    }
+    default: {
+      const input = document.createElement('input');
+      input.type = pergunta.type === 'number' ? 'number' : 'text';
+      if (pergunta.maxLength) input.maxLength = pergunta.maxLength;
+      if (pergunta.min !== undefined) input.min = pergunta.min;
+      if (pergunta.max !== undefined) input.max = pergunta.max;
+      div.appendChild(input);
+    }
+  }
+  return div;
+}
+
+// Cria o bloco de perguntas de uma pessoa do convite. Convidados nomeados
+// aparecem com o nome do convite; acompanhantes informam o próprio nome.
+function criarBlocoPessoa(nome, acompanhante) {
+  const id = proximoBlocoPessoa++;
+  const bloco = document.createElement('fieldset');
+  bloco.className = 'pessoa';
+  bloco.dataset.nome = nome;
+  if (acompanhante) bloco.dataset.acompanhante = 'true';
+
+  bloco.innerHTML = `
+    <legend>${escapeHtml(nome)}</legend>
+    ${acompanhante ? `
+      <p>Nome do acompanhante</p>
+      <input type="text" name="nomeAcompanhante" maxlength="120" required>
+    ` : ''}
+
+    <p>Vai comparecer?</p>
+    <label><input type="radio" name="presenca-${id}" value="Sim" required> Sim</label>
+    <label><input type="radio" name="presenca-${id}" value="Não"> Não</label>
+
+    <div class="questionario" style="display: none;"></div>
   `;
+
+  const perguntas = bloco.querySelector('.questionario');
+  questionario.questions.forEach(pergunta => perguntas.appendChild(criarCampoPergunta(pergunta, id)));
+
+  // Mostra só as perguntas que se aplicam, como o servidor exige
+  bloco.addEventListener('change', () => lerBlocoPessoa(bloco));
+  lerBlocoPessoa(bloco);
+
+  return bloco;
+}
+
+// Recria os blocos dos acompanhantes conforme a quantidade escolhida
+function criarBlocosAcompanhantes(quantidade) {
+  const container = document.getElementById('acompanhantes');
+  container.innerHTML = '';
+  for (let i = 1; i <= quantidade; i++) {
+    container.appendChild(criarBlocoPessoa(`Acompanhante ${i}`, true));
+  }
+}
+
+// Lê as respostas de um bloco de pessoa no formato da API e atualiza quais
+// perguntas aparecem. Uma pergunta aparece quando as respostas listadas em
+// showIf coincidem; respostas de perguntas ocultas não são enviadas.
+function lerBlocoPessoa(bloco) {
+  const presenca = bloco.querySelector('input[name^="presenca-"]:checked');
+  const acompanhante = bloco.dataset.acompanhante === 'true';
+  const vai = presenca && presenca.value === 'Sim';
+  bloco.querySelector('.questionario').style.display = vai ? 'block' : 'none';
+
+  const respostas = {};
+  let completo = true;
+  questionario.questions.forEach(pergunta => {
+    const div = bloco.querySelector(`.pergunta[data-id="${pergunta.id}"]`);
+    const visivel = Object.entries(pergunta.showIf || {}).every(([id, valor]) => respostas[id] === valor);
+    div.style.display = visivel ? 'block' : 'none';
+    if (!vai || !visivel) return;
+
+    const campo = div.querySelector(pergunta.type === 'radio' ? 'input:checked' : 'input, select');
+    const valor = campo ? campo.value.trim() : '';
+    if (valor) {
+      respostas[pergunta.id] = valor;
+    } else if (pergunta.required) {
+      completo = false;
+    }
+  });
+
+  return {
+    name: acompanhante ? bloco.querySelector('input[name="nomeAcompanhante"]').value.trim() : bloco.dataset.nome,
+    companion: acompanhante,
+    attending: presenca ? presenca.value : '',
+    answers: vai ? respostas : undefined,
+    completo
+  };
+}
+
+// Resposta já enviada para o convite carregado; com ela, o envio altera a
+// resposta em vez de criar outra
+let respostaExistente = null;
+
+// Busca o convite pelo código e cria um bloco de perguntas por convidado,
+// preenchido com a resposta já enviada, se houver
+async function carregarConvite(codigo) {
+  const convite = document.getElementById('convite');
+  const pessoas = document.getElementById('pessoas');
+  const campoAcompanhantes = document.getElementById('campoAcompanhantes');
+  const botao = document.getElementById('btnEnviar');
+  codigo = codigo.trim();
+  if (!codigo) return;
+  if (!questionario) await carregarQuestionario();
+  if (!questionario) return;
+
+  pessoas.innerHTML = '';
+  criarBlocosAcompanhantes(0);
+  respostaExistente = null;
+  botao.disabled = false;
+
+  try {
+    const response = await fetch(`/api/rsvp/invitation/${encodeURIComponent(codigo)}`);
+    if (response.status === 429) {
+      convite.innerHTML = '<p class="error-message">Muitas tentativas. Aguarde alguns minutos e tente de novo.</p>';
+      campoAcompanhantes.style.display = 'none';
+      return;
+    }
+    if (!response.ok) {
+      convite.innerHTML = '<p class="error-message">Convite não encontrado. Confira o código no seu convite.</p>';
+      campoAcompanhantes.style.display = 'none';
+      return;
+    }
+    const dados = await response.json();
+    respostaExistente = dados.response || null;
+
+    const prazo = dados.deadline ? new Date(dados.deadline).toLocaleString('pt-BR', { dateStyle: 'short', timeStyle: 'short' }) : '';
+    let aviso = 'Responda por cada pessoa do convite.';
+    if (dados.closed) {
+      aviso = `O prazo para confirmar presença terminou em ${prazo}. Para mudanças, fale com os noivos.`;
+    } else if (respostaExistente) {
+      aviso = 'Vocês já responderam. Altere as respostas e envie de novo, ou cancele a confirmação.';
+    }
+    convite.innerHTML = `
+      <p>Olá, <strong>${escapeHtml(dados.name)}</strong>! ${aviso}</p>
+      ${prazo && !dados.closed ? `<p>Alterações são aceitas até ${prazo}.</p>` : ''}
+      ${respostaExistente && !dados.closed ? '<button type="button" onclick="cancelarConfirmacao()">Cancelar confirmação</button>' : ''}
+    `;
+    botao.disabled = dados.closed;
+
+    const anteriores = respostaExistente ? respostaExistente.attendees : [];
+    dados.guests.forEach(nome => {
+      const bloco = criarBlocoPessoa(nome, false);
+      const anterior = anteriores.find(pessoa => !pessoa.companion && pessoa.name.toLowerCase() === nome.toLowerCase());
+      if (anterior) preencherBlocoPessoa(bloco, anterior);
+      pessoas.appendChild(bloco);
+    });
+
+    const select = campoAcompanhantes.querySelector('select');
+    select.innerHTML = '';
+    for (let i = 0; i <= dados.maxCompanions; i++) {
+      select.add(new Option(i, i));
+    }
+    campoAcompanhantes.style.display = dados.maxCompanions > 0 ? 'block' : 'none';
+
+    const acompanhantes = anteriores.filter(pessoa => pessoa.companion).slice(0, dados.maxCompanions);
+    if (acompanhantes.length > 0) {
+      select.value = acompanhantes.length;
+      criarBlocosAcompanhantes(acompanhantes.length);
+      document.querySelectorAll('#acompanhantes .pessoa').forEach((bloco, i) => preencherBlocoPessoa(bloco, acompanhantes[i]));
+    }
+
+    const contato = document.querySelector('input[name="contato"]');
+    if (respostaExistente && respostaExistente.contact) contato.value = respostaExistente.contact;
+  } catch (error) {
+    console.error('Erro ao buscar convite:', error);
+  }
+}
+
+// Preenche um bloco de pessoa com uma resposta já enviada
+function preencherBlocoPessoa(bloco, pessoa) {
+  const nome = bloco.querySelector('input[name="nomeAcompanhante"]');
+  if (nome) nome.value = pessoa.name;
+
+  const presenca = bloco.querySelector(`input[name^="presenca-"][value="${pessoa.attending}"]`);
+  if (presenca) presenca.checked = true;
+
+  Object.entries(pessoa.answers || {}).forEach(([id, valor]) => {
+    const div = bloco.querySelector(`.pergunta[data-id="${id}"]`);
+    if (!div) return;
+    const opcao = Array.from(div.querySelectorAll('input[type="radio"]')).find(input => input.value === valor);
+    if (opcao) {
+      opcao.checked = true;
+    } else {
+      const campo = div.querySelector('input, select');
+      if (campo) campo.value = valor;
+    }
+  });
+
+  lerBlocoPessoa(bloco);
+}
+
+// Cancela a confirmação do convite carregado: ninguém do convite comparece
+async function cancelarConfirmacao() {
+  const codigo = document.querySelector('input[name="codigo"]').value.trim();
+  const resultadoEnvio = document.getElementById('resultadoEnvio');
+  if (!confirm('Cancelar a confirmação de presença de todos do convite?')) return;
+
+  try {
+    const response = await fetch(`/api/rsvp/invitation/${encodeURIComponent(codigo)}`, { method: 'DELETE' });
+    const corpo = await response.json().catch(() => ({}));
+    if (!response.ok) {
+      resultadoEnvio.innerHTML = `<div class="error-message"><p>${escapeHtml(corpo.error || 'Não foi possível cancelar a confirmação.')}</p></div>`;
+      return;
+    }
+
+    await carregarConvite(codigo);
+    resultadoEnvio.innerHTML = `
+      <div style="color: green; margin-top: 20px;">
+        <p>Confirmação cancelada. Se mudarem de ideia, é só responder de novo dentro do prazo.</p>
+      </div>
+    `;
+  } catch (error) {
+    console.error('Erro ao cancelar confirmação:', error);
+    resultadoEnvio.innerHTML = '<div class="error-message"><p>Erro de conexão. Verifique sua internet e tente novamente.</p></div>';
+  }
+}
+
+// Chave do localStorage com o ID da última confirmação enviada deste navegador
+const RSVP_STORAGE_KEY = 'rsvpId';
+
+// Mostra a confirmação já enviada por este navegador, se houver
+async function mostrarConfirmacaoAnterior() {
+  const id = localStorage.getItem(RSVP_STORAGE_KEY);
+  if (!id) return;
+
+  try {
+    const response = await fetch(`/api/rsvp/${encodeURIComponent(id)}`);
+    if (!response.ok) {
+      if (response.status === 404) localStorage.removeItem(RSVP_STORAGE_KEY);
+      return;
+    }
+    const rsvp = await response.json();
+    const data = new Date(rsvp.updatedAt || rsvp.createdAt).toLocaleString('pt-BR');
+    const presentes = rsvp.attendees.filter(pessoa => pessoa.attending === 'Sim').length;
+    document.getElementById('resultadoEnvio').innerHTML = `
+      <div style="color: green; margin-top: 20px;">
+        <p>${escapeHtml(rsvp.name)}, recebemos sua confirmação em ${data} (${presentes} pessoa(s) confirmada(s)). Obrigado!</p>
+      </div>
+    `;
+  } catch (error) {
+    console.error('Erro ao buscar confirmação anterior:', error);
+  }
+}
+
+// Escapa texto digitado pelo usuário antes de inseri-lo no HTML
+function escapeHtml(texto) {
+  const div = document.createElement('div');
+  div.textContent = texto;
+  return div.innerHTML;
 }
 
 // Função para enviar as respostas do formulário
-function enviarFormulario() {
-  const vegetariano = document.querySelector('input[name="vegetariano"]:checked');
-  const frutosDoMar = document.querySelector('input[name="frutosDoMar"]:checked');
-  const preferenciaSelect = document.querySelector('select[name="preferencia"]');
+async function enviarFormulario() {
+  const codigo = document.querySelector('input[name="codigo"]');
+  const contato = document.querySelector('input[name="contato"]');
+  const blocos = Array.from(document.querySelectorAll('#formDinamico .pessoa'));
+  const pessoas = blocos.map(lerBlocoPessoa);
 
-  if (!vegetariano || !frutosDoMar) {
+  const incompleta = pessoas.some(pessoa => !pessoa.name || !pessoa.attending || !pessoa.completo);
+  if (!codigo || !codigo.value.trim() || pessoas.length === 0 || incompleta) {
     alert('Por favor, preencha todos os campos obrigatórios.');
     return;
   }
+  pessoas.forEach(pessoa => delete pessoa.completo);
 
   const respostas = {
-    vegetariano: vegetariano.value,
-    frutosDoMar: frutosDoMar.value,
-    preferencia: preferenciaSelect ? preferenciaSelect.value : ''
+    code: codigo.value.trim(),
+    contact: contato ? contato.value.trim() : '',
+    attendees: pessoas
   };
 
-  // Exibe mensagem de confirmação
+  const botao = document.getElementById('btnEnviar');
   const resultadoEnvio = document.getElementById('resultadoEnvio');
-  resultadoEnvio.innerHTML = `
-    <div style="color: green; margin-top: 20px;">
-      <h3>Respostas enviadas com sucesso!</h3>
-      <p>Agradecemos a sua confirmação.</p>
-    </div>
-  `;
+  botao.disabled = true;
+  // Com o prazo encerrado, o botão continua desabilitado
+  let encerrado = false;
+
+  try {
+    // Convite já respondido: altera a resposta existente
+    const url = respostaExistente ? `/api/rsvp/invitation/${encodeURIComponent(respostas.code)}` : '/api/rsvp';
+    const response = await fetch(url, {
+      method: respostaExistente ? 'PUT' : 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(respostas)
+    });
+    const corpo = await response.json().catch(() => ({}));
+
+    if (!response.ok) {
+      encerrado = corpo.closed === true;
+      // Os erros por pessoa vêm como "attendees[0].answers.pergunta";
+      // mostra o nome da pessoa e o texto da pergunta
+      const detalhes = corpo.fields ? Object.entries(corpo.fields).map(([campo, mensagem]) => {
+        const indice = campo.match(/^attendees\[(\d+)\]/);
+        const pessoa = indice && pessoas[indice[1]] ? `${pessoas[indice[1]].name || blocos[indice[1]].dataset.nome}: ` : '';
+        const resposta = campo.match(/\.answers\.(\w+)$/);
+        const pergunta = resposta && questionario.questions.find(p => p.id === resposta[1]);
+        return escapeHtml(pessoa + (pergunta ? `${pergunta.label} — ${mensagem}` : mensagem));
+      }).join('<br>') : '';
+      resultadoEnvio.innerHTML = `
+        <div class="error-message">
+          <p>${escapeHtml(corpo.error || 'Não foi possível enviar as respostas.')}</p>
+          ${detalhes ? `<p>${detalhes}</p>` : ''}
+        </div>
+      `;
+      return;
+    }
+
+    localStorage.setItem(RSVP_STORAGE_KEY, corpo.id);
+    const alteracao = respostaExistente !== null;
+    respostaExistente = corpo;
+
+    // Exibe mensagem de confirmação
+    resultadoEnvio.innerHTML = `
+      <div style="color: green; margin-top: 20px;">
+        <h3>${alteracao ? 'Respostas alteradas com sucesso!' : 'Respostas enviadas com sucesso!'}</h3>
+        <p>Agradecemos a sua confirmação.</p>
+      </div>
+    `;
+  } catch (error) {
+    console.error('Erro ao enviar confirmação:', error);
+    resultadoEnvio.innerHTML = `
+      <div class="error-message">
+        <p>Erro de conexão. Verifique sua internet e tente novamente.</p>
+      </div>
+    `;
+  } finally {
+    botao.disabled = encerrado;
+  }
 }
 
 // Ao carregar a página, inicializa os componentes necessários
@@ -387,6 +847,7 @@ document.addEventListener('DOMContentLoaded', () => {
     // Se estiver na página da galeria, carrega as imagens
     if (window.location.pathname.includes('/galeria')) {
         console.log("Estamos na página da galeria, iniciando carregamento");
+        loadAlbums();
         loadImages();
         
         // Configura o botão de fechar modal